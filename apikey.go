@@ -0,0 +1,129 @@
+package vio
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// API key scopes.
+const (
+	// ScopeLookup allows looking up geolocation data.
+	ScopeLookup = "lookup"
+
+	// ScopeBatch allows batch operations.
+	ScopeBatch = "batch"
+
+	// ScopeAdmin allows managing the service. It implies all other scopes.
+	ScopeAdmin = "admin"
+)
+
+// Scopes available for API keys.
+var Scopes = []string{ScopeLookup, ScopeBatch, ScopeAdmin}
+
+// APIKey identifies a client of the API.
+type APIKey struct {
+	ID        int64
+	Name      string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+
+	// MonthlyQuota of lookups. Unlimited if nil.
+	MonthlyQuota *int64
+}
+
+// HasScope checks whether the API key is allowed to use the given scope.
+func (k *APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, scope) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// apiKeyPrefix makes API keys easy to recognize, e.g., by secret scanners.
+const apiKeyPrefix = "vio_"
+
+// ErrInvalidAPIKey is returned when an API key doesn't exist or was revoked.
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// ErrAPIKeyNotFound is returned when trying to modify an API key that doesn't exist.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// hashAPIKey returns the hash of an API key as stored on the database.
+func hashAPIKey(key string) []byte {
+	h := sha256.Sum256([]byte(key))
+	return h[:]
+}
+
+// CreateAPIKey creates a new API key with the given scopes and monthly quota (unlimited if nil).
+// The returned key is only available at creation time.
+func (s *Service) CreateAPIKey(ctx context.Context, name string, scopes []string, monthlyQuota *int64) (key string, apiKey *APIKey, err error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, errors.New("missing API key name")
+	}
+	if len(scopes) == 0 {
+		return "", nil, errors.New("missing API key scopes")
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return "", nil, fmt.Errorf("invalid API key scope: %q", scope)
+		}
+	}
+	if monthlyQuota != nil && *monthlyQuota < 0 {
+		return "", nil, errors.New("monthly quota cannot be negative")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	apiKey = &APIKey{
+		Name:         name,
+		Scopes:       slices.Compact(scopes),
+		MonthlyQuota: monthlyQuota,
+	}
+	if err := s.db.CreateAPIKey(ctx, apiKey, hashAPIKey(key)); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// AuthenticateAPIKey returns the API key if it is valid.
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := s.db.GetAPIKey(ctx, hashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil || apiKey.RevokedAt != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return apiKey, nil
+}
+
+// ListAPIKeys returns all API keys, including revoked ones.
+func (s *Service) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	return s.db.ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey revokes an API key.
+func (s *Service) RevokeAPIKey(ctx context.Context, id int64) error {
+	return s.db.RevokeAPIKey(ctx, id)
+}
+
+// SetAPIKeyQuota sets the monthly quota of an API key (unlimited if nil).
+func (s *Service) SetAPIKeyQuota(ctx context.Context, id int64, monthlyQuota *int64) error {
+	if monthlyQuota != nil && *monthlyQuota < 0 {
+		return errors.New("monthly quota cannot be negative")
+	}
+	return s.db.SetAPIKeyQuota(ctx, id, monthlyQuota)
+}
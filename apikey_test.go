@@ -0,0 +1,112 @@
+package vio_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/henvic/pgtools/sqltest"
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServiceAPIKeys(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	if _, _, err := service.CreateAPIKey(ctx, "", []string{vio.ScopeLookup}, nil); err == nil || err.Error() != "missing API key name" {
+		t.Errorf("Service.CreateAPIKey() should fail without name, got %v", err)
+	}
+	if _, _, err := service.CreateAPIKey(ctx, "team", []string{"root"}, nil); err == nil || err.Error() != `invalid API key scope: "root"` {
+		t.Errorf("Service.CreateAPIKey() should fail with unknown scope, got %v", err)
+	}
+
+	key, apiKey, err := service.CreateAPIKey(ctx, "fraud", []string{vio.ScopeLookup, vio.ScopeBatch, vio.ScopeLookup}, nil)
+	if err != nil {
+		t.Fatalf("Service.CreateAPIKey() error = %v", err)
+	}
+	if !strings.HasPrefix(key, "vio_") {
+		t.Errorf("API key %q should have the vio_ prefix", key)
+	}
+	want := &vio.APIKey{
+		ID:        apiKey.ID,
+		Name:      "fraud",
+		Scopes:    []string{vio.ScopeBatch, vio.ScopeLookup},
+		CreatedAt: time.Now(),
+	}
+	if !cmp.Equal(want, apiKey, cmpopts.EquateApproxTime(time.Minute)) {
+		t.Errorf("Service.CreateAPIKey() mismatch: %v", cmp.Diff(want, apiKey))
+	}
+
+	got, err := service.AuthenticateAPIKey(ctx, key)
+	if err != nil {
+		t.Fatalf("Service.AuthenticateAPIKey() error = %v", err)
+	}
+	if !cmp.Equal(apiKey, got, cmpopts.EquateApproxTime(time.Millisecond)) {
+		t.Errorf("Service.AuthenticateAPIKey() mismatch: %v", cmp.Diff(apiKey, got))
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, key+"x"); err != vio.ErrInvalidAPIKey {
+		t.Errorf("Service.AuthenticateAPIKey() error = %v, want %v", err, vio.ErrInvalidAPIKey)
+	}
+
+	quota := int64(1000)
+	if err := service.SetAPIKeyQuota(ctx, apiKey.ID, &quota); err != nil {
+		t.Errorf("Service.SetAPIKeyQuota() error = %v", err)
+	}
+	if got, err = service.AuthenticateAPIKey(ctx, key); err != nil || got.MonthlyQuota == nil || *got.MonthlyQuota != quota {
+		t.Errorf("Service.AuthenticateAPIKey() should return the monthly quota, got %+v (%v)", got, err)
+	}
+
+	if err := service.RevokeAPIKey(ctx, apiKey.ID); err != nil {
+		t.Errorf("Service.RevokeAPIKey() error = %v", err)
+	}
+	if err := service.RevokeAPIKey(ctx, apiKey.ID+1000); err != vio.ErrAPIKeyNotFound {
+		t.Errorf("Service.RevokeAPIKey() error = %v, want %v", err, vio.ErrAPIKeyNotFound)
+	}
+	if _, err := service.AuthenticateAPIKey(ctx, key); err != vio.ErrInvalidAPIKey {
+		t.Errorf("Service.AuthenticateAPIKey() of revoked key error = %v, want %v", err, vio.ErrInvalidAPIKey)
+	}
+
+	keys, err := service.ListAPIKeys(ctx)
+	if err != nil {
+		t.Fatalf("Service.ListAPIKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0].ID != apiKey.ID || keys[0].RevokedAt == nil {
+		t.Errorf("Service.ListAPIKeys() returned unexpected keys: %+v", keys)
+	}
+}
+
+func TestServiceAuthenticateAPIKeyDatabaseError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	m := mock.NewMockDB(ctrl)
+	m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(nil, errors.New("unexpected error"))
+	if _, err := vio.NewService(m).AuthenticateAPIKey(context.Background(), "vio_key"); err == nil || err.Error() != "unexpected error" {
+		t.Errorf("Service.AuthenticateAPIKey() error = %v, want unexpected error", err)
+	}
+}
+
+func TestAPIKeyHasScope(t *testing.T) {
+	t.Parallel()
+	key := vio.APIKey{Scopes: []string{vio.ScopeLookup}}
+	if !key.HasScope(vio.ScopeLookup) || key.HasScope(vio.ScopeBatch) || key.HasScope(vio.ScopeAdmin) {
+		t.Errorf("unexpected scopes for lookup key")
+	}
+	admin := vio.APIKey{Scopes: []string{vio.ScopeAdmin}}
+	if !admin.HasScope(vio.ScopeLookup) || !admin.HasScope(vio.ScopeBatch) || !admin.HasScope(vio.ScopeAdmin) {
+		t.Errorf("admin scope should imply all other scopes")
+	}
+}
@@ -0,0 +1,226 @@
+package vio
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrNoCheckpoint is returned when resuming an import without an interrupted import run of the data dump.
+	ErrNoCheckpoint = errors.New("no import run to resume")
+
+	// ErrCheckpointMismatch is returned when resuming an import with input different from the one of the checkpoint.
+	ErrCheckpointMismatch = errors.New("input doesn't match the import run checkpoint")
+)
+
+// importCheckpoint of an import run: the records of the data dump up to the input offset were staged.
+type importCheckpoint struct {
+	// batch is the number of the last batch staged.
+	batch int
+
+	// file of the data dump with the last record read, and its line.
+	file int
+
+	line int64
+
+	// offset of the input after the last record read, counting the files before it.
+	// Compressed files are decompressed as they are read, so the offset is of the decompressed input,
+	// and resuming decompresses the input up to it again.
+	offset int64
+
+	// accepted and discarded records when the records were parsed.
+	accepted  int
+	discarded int
+
+	// rejected is the number of accepted records rejected by the database when staged.
+	// Until the checkpoint is saved, it only has the ones of the batch.
+	rejected int
+
+	// checksum is the SHA-256 of the files up to the offset, computed when the batch is read.
+	// It is of the decompressed input too, so a file compressed again with the same content matches it.
+	checksum string
+}
+
+// checksumReader computes the SHA-256 of the input as it is consumed.
+// The CSV reader reads ahead of the records it returns, so only the input read past the last record is kept until it is hashed.
+// The checksum isn't safe for concurrent use: it is computed when reading the records.
+type checksumReader struct {
+	r io.Reader
+
+	h       hash.Hash
+	hashed  int64
+	pending []byte
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.pending = append(c.pending, p[:n]...)
+	return n, err
+}
+
+// advance the checksum up to the offset of the input, which must not be before the offset of the previous call.
+func (c *checksumReader) advance(offset int64) {
+	n := offset - c.hashed
+	c.h.Write(c.pending[:n])
+	c.pending = c.pending[:copy(c.pending, c.pending[n:])]
+	c.hashed = offset
+}
+
+// discard n bytes of the input, hashing them without keeping them.
+func (c *checksumReader) discard(n int64) error {
+	buffered := min(n, int64(len(c.pending)))
+	c.advance(c.hashed + buffered)
+	copied, err := io.CopyN(c.h, c.r, n-buffered)
+	c.hashed += copied
+	return err
+}
+
+// drain the rest of the input, hashing it without keeping it.
+func (c *checksumReader) drain() error {
+	c.advance(c.read())
+	n, err := io.Copy(c.h, c.r)
+	c.hashed += n
+	return err
+}
+
+// checksum of the input up to the offset it was advanced to.
+func (c *checksumReader) checksum() string {
+	return hex.EncodeToString(c.h.Sum(nil))
+}
+
+// sum is the checksum of all the input read.
+func (c *checksumReader) sum() string {
+	c.advance(c.read())
+	return c.checksum()
+}
+
+// read is the size of the input read.
+func (c *checksumReader) read() int64 {
+	return c.hashed + int64(len(c.pending))
+}
+
+// checkpoints of an import run. Workers may stage batches out of order, so the checkpoint
+// is only moved to a batch once all the batches before it were staged.
+type checkpoints struct {
+	runID int64
+	input *checksumReader
+
+	mu   sync.Mutex
+	last importCheckpoint
+	done map[int]importCheckpoint
+}
+
+// save the checkpoint of a staged batch, moving the checkpoint of the import run if all the batches before it were staged.
+func (c *checkpoints) save(ctx context.Context, db importDB, staged importCheckpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[staged.batch] = staged
+	last, ok := c.last, false
+	for {
+		cp, found := c.done[last.batch+1]
+		if !found {
+			break
+		}
+		delete(c.done, cp.batch)
+		cp.rejected += last.rejected
+		last, ok = cp, true
+	}
+	if !ok {
+		return nil
+	}
+	const saveCheckpointQuery = `INSERT INTO import_run_checkpoints (run_id, batch, file, line, input_offset, accepted, discarded, rejected, checksum)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (run_id) DO UPDATE SET batch = EXCLUDED.batch, file = EXCLUDED.file, line = EXCLUDED.line, input_offset = EXCLUDED.input_offset,
+accepted = EXCLUDED.accepted, discarded = EXCLUDED.discarded, rejected = EXCLUDED.rejected, checksum = EXCLUDED.checksum,
+updated_at = now()`
+	if _, err := db.Exec(ctx, saveCheckpointQuery,
+		c.runID, last.batch, last.file, last.line, last.offset, last.accepted, last.discarded, last.rejected, last.checksum); err != nil {
+		return fmt.Errorf("cannot save checkpoint: %w", err)
+	}
+	c.last = last
+	return nil
+}
+
+// skip n bytes of the current file, up to the offset of the checkpoint the import run was resumed from.
+// The input read must match the checksum of the checkpoint.
+func (c *checkpoints) skip(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("%w: offset %d is before the current file", ErrCheckpointMismatch, c.last.offset)
+	}
+	if err := c.input.discard(n); err != nil {
+		return fmt.Errorf("%w: cannot read up to offset %d: %w", ErrCheckpointMismatch, c.last.offset, err)
+	}
+	if sum := c.input.checksum(); sum != c.last.checksum {
+		return fmt.Errorf("%w: checksum up to offset %d is %s, expected %s", ErrCheckpointMismatch, c.last.offset, sum, c.last.checksum)
+	}
+	return nil
+}
+
+// resume the last import run of the data dump from its checkpoint, if it failed.
+// The input up to the offset of the checkpoint is skipped when streamed, and must match its checksum.
+func (i *Importer) resume(ctx context.Context, src ImportSource, cps *checkpoints, stats *ImportStats) error {
+	var (
+		runID        int64
+		status, mode string
+	)
+	const lastRunQuery = `SELECT id, status, mode FROM import_runs WHERE source = $1 ORDER BY id DESC LIMIT 1`
+	switch err := i.db.QueryRow(ctx, lastRunQuery, src.Name).Scan(&runID, &status, &mode); {
+	case err == pgx.ErrNoRows:
+		return fmt.Errorf("%w: %q was never imported", ErrNoCheckpoint, src.Name)
+	case err != nil:
+		return fmt.Errorf("cannot find import run to resume: %w", err)
+	case status != ImportRunFailed:
+		return fmt.Errorf("%w: last import run %d of %q is %s", ErrNoCheckpoint, runID, src.Name, status)
+	case mode != i.opts.Mode:
+		return fmt.Errorf("%w: import run %d is a %s import", ErrNoCheckpoint, runID, mode)
+	}
+
+	var cp importCheckpoint
+	const checkpointQuery = `SELECT batch, file, line, input_offset, accepted, discarded, rejected, checksum
+FROM import_run_checkpoints WHERE run_id = $1`
+	switch err := i.db.QueryRow(ctx, checkpointQuery, runID).Scan(
+		&cp.batch, &cp.file, &cp.line, &cp.offset, &cp.accepted, &cp.discarded, &cp.rejected, &cp.checksum); {
+	case err == pgx.ErrNoRows:
+		return fmt.Errorf("%w: import run %d has no checkpoint", ErrNoCheckpoint, runID)
+	case err != nil:
+		return fmt.Errorf("cannot get checkpoint: %w", err)
+	}
+
+	// Batches staged after the checkpoint are staged again.
+	const trimQuery = `DELETE FROM import_run_rows WHERE run_id = $1 AND seq >= $2`
+	if _, err := i.db.Exec(ctx, trimQuery, runID, cp.accepted); err != nil {
+		return fmt.Errorf("cannot delete records staged after the checkpoint: %w", err)
+	}
+	// Staged records are lost if the database crashes, as the staging table is unlogged.
+	var staged int
+	const stagedQuery = `SELECT count(*) FROM import_run_rows WHERE run_id = $1`
+	if err := i.db.QueryRow(ctx, stagedQuery, runID).Scan(&staged); err != nil {
+		return fmt.Errorf("cannot count staged records: %w", err)
+	}
+	if want := cp.accepted - cp.rejected; staged != want {
+		return fmt.Errorf("%w: import run %d has %d of the %d records staged", ErrNoCheckpoint, runID, staged, want)
+	}
+
+	const resumeRunQuery = `UPDATE import_runs SET status = 'running', error = '', finished_at = NULL WHERE id = $1`
+	if _, err := i.db.Exec(ctx, resumeRunQuery, runID); err != nil {
+		return fmt.Errorf("cannot resume import run: %w", err)
+	}
+	stats.RunID, stats.Accepted, stats.Discarded = runID, cp.accepted, cp.discarded
+	cps.runID, cps.last = runID, cp
+	i.log.Info("Import run resumed",
+		slog.Int64("run_id", runID),
+		slog.String("source", src.Name),
+		slog.Int("batch", cp.batch),
+		slog.Int64("line", cp.line),
+		slog.Int64("offset", cp.offset),
+	)
+	return nil
+}
@@ -0,0 +1,132 @@
+package vio
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+// repeatReader reads the line repeatedly, up to size bytes.
+type repeatReader struct {
+	line []byte
+	size int64
+	read int64
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.read >= r.size {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), r.size-r.read)]
+	var n int
+	for n < len(p) {
+		n += copy(p[n:], r.line[(r.read+int64(n))%int64(len(r.line)):])
+	}
+	r.read += int64(n)
+	return n, nil
+}
+
+func TestCheckpointsSkip(t *testing.T) {
+	const (
+		line   = "1.2.3.4,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332\n"
+		offset = int64(len(line)) * 4 << 20 // About 256MB.
+		size   = offset + int64(len(line))*1000
+	)
+	want := sha256.New()
+	if _, err := io.Copy(want, &repeatReader{line: []byte(line), size: offset}); err != nil {
+		t.Fatal(err)
+	}
+	cps := &checkpoints{
+		input: &checksumReader{
+			r: &repeatReader{line: []byte(line), size: size},
+			h: sha256.New(),
+		},
+		last: importCheckpoint{
+			offset:   offset,
+			checksum: hex.EncodeToString(want.Sum(nil)),
+		},
+	}
+	if err := cps.skip(offset); err != nil {
+		t.Fatalf("cannot skip: %v", err)
+	}
+	if got := cps.input.read(); got != offset {
+		t.Errorf("input read = %d, want %d", got, offset)
+	}
+	if got := cap(cps.input.pending); got != 0 {
+		t.Errorf("skipped input was kept in memory: %d bytes", got)
+	}
+
+	// Only what the CSV reader reads ahead of the records is kept.
+	stream := csv.NewReader(cps.input)
+	var records int
+	for {
+		_, err := stream.Read()
+		cps.input.advance(offset + stream.InputOffset())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("cannot read record: %v", err)
+		}
+		if got := len(cps.input.pending); got > 8<<10 {
+			t.Fatalf("input kept in memory after record %d: %d bytes", records, got)
+		}
+		records++
+	}
+	if records != 1000 {
+		t.Errorf("got %d records, want 1000", records)
+	}
+	if _, err := io.Copy(want, &repeatReader{line: []byte(line), size: size - offset}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cps.input.sum(), hex.EncodeToString(want.Sum(nil)); got != want {
+		t.Errorf("checksum = %s, want %s", got, want)
+	}
+}
+
+func TestCheckpointsSkipMismatch(t *testing.T) {
+	cps := &checkpoints{
+		input: &checksumReader{
+			r: &repeatReader{line: []byte("1.2.3.4,SI,Nepal,DuBuquemouth,1,2\n"), size: 1 << 20},
+			h: sha256.New(),
+		},
+		last: importCheckpoint{
+			offset:   1 << 10,
+			checksum: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+	if err := cps.skip(1 << 10); !errors.Is(err, ErrCheckpointMismatch) {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestChecksumReaderDrain(t *testing.T) {
+	const (
+		line = "1.2.3.4,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332\n"
+		size = int64(len(line)) * 4 << 20 // About 256MB.
+	)
+	want := sha256.New()
+	if _, err := io.Copy(want, &repeatReader{line: []byte(line), size: size}); err != nil {
+		t.Fatal(err)
+	}
+	// Files before the one of the checkpoint are drained.
+	input := &checksumReader{
+		r: &repeatReader{line: []byte(line), size: size},
+		h: sha256.New(),
+	}
+	if err := input.drain(); err != nil {
+		t.Fatalf("cannot drain: %v", err)
+	}
+	if got := input.read(); got != size {
+		t.Errorf("input read = %d, want %d", got, size)
+	}
+	if got := cap(input.pending); got != 0 {
+		t.Errorf("drained input was kept in memory: %d bytes", got)
+	}
+	if got, want := input.sum(), hex.EncodeToString(want.Sum(nil)); got != want {
+		t.Errorf("checksum = %s, want %s", got, want)
+	}
+}
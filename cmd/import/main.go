@@ -4,36 +4,53 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/config"
+	"github.com/henvic/vio/internal/decompress"
+	"github.com/henvic/vio/internal/logging"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/tracelog"
-)
-
-var (
-	file      = flag.String("file", "data_dump.csv", "Data dump file")
-	batchSize = flag.Int("batch-size", 25000, "Batch size for the importer")
 )
 
 func main() {
-	flag.Parse()
+	conf, err := config.Load(flag.CommandLine, config.Importer, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	log, closer, err := logging.New(conf.Log, new(slog.LevelVar))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(log)
 	p := program{
-		log: slog.Default(),
+		log:    log,
+		config: conf,
 	}
 
-	if err := p.run(); err != nil {
+	err = p.run()
+	if err != nil {
 		p.log.Error("application terminated", slog.Any("error", err))
+	}
+	closer.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
 type program struct {
-	log *slog.Logger
-	db  *pgxpool.Pool
+	log    *slog.Logger
+	config *config.Config
+	db     *pgxpool.Pool
 }
 
 func (p *program) run() error {
@@ -47,9 +64,8 @@ func (p *program) run() error {
 		return err
 	}
 
-	conf.ConnConfig.Tracer = &tracelog.TraceLog{
-		Logger:   pgxLogger{log: p.log},
-		LogLevel: tracelog.LogLevelError,
+	if conf.ConnConfig.Tracer, err = logging.NewTracer(p.log, p.config.Log.PgxLevel); err != nil {
+		return err
 	}
 
 	p.db, err = pgxpool.NewWithConfig(ctx, conf)
@@ -61,18 +77,55 @@ func (p *program) run() error {
 
 	ec := make(chan error, 1)
 	go func() {
-		input, err := os.Open(*file)
+		files, err := expandFiles(p.config.Import.Files)
 		if err != nil {
 			ec <- err
 			return
 		}
-		defer input.Close()
 
-		importer := vio.NewImporter(*batchSize, p.log, p.db)
-		stats, err := importer.Stream(ctx, input)
+		opts := vio.ImportOptions{
+			BatchSize:     p.config.Import.BatchSize,
+			Workers:       p.config.Import.Workers,
+			Mode:          p.config.Import.Mode,
+			MaxPruneRatio: p.config.Import.MaxPruneRatio,
+			DryRun:        p.config.Import.DryRun,
+			Resume:        p.config.Import.Resume,
+			Thresholds: vio.ImportThresholds{
+				MaxDiscardRatio: p.config.Import.MaxDiscardRatio,
+				MaxChangeRatio:  p.config.Import.MaxChangeRatio,
+				MinRecords:      p.config.Import.MinRecords,
+				MaxCountryDelta: p.config.Import.MaxCountryDelta,
+			},
+			Retry: vio.ImportRetry{
+				MaxAttempts: p.config.Import.MaxAttempts,
+				Backoff:     p.config.Import.RetryBackoff,
+				MaxBackoff:  p.config.Import.RetryMaxBackoff,
+			},
+		}
+		if p.config.Import.Rejects != "" {
+			rejects, err := os.Create(p.config.Import.Rejects)
+			if err != nil {
+				ec <- err
+				return
+			}
+			defer rejects.Close()
+			opts.Rejects = rejects
+		}
+
+		importer := vio.NewImporter(p.log, p.db, opts)
+		stats, err := importer.StreamFiles(ctx, files, vio.ImportSource{
+			Name:     sourceName(p.config.Import.Files),
+			Operator: operator(),
+		})
 
 		if stats != nil {
 			p.log.Info("import stats", slog.Any("stats", stats))
+			for _, f := range stats.Files {
+				p.log.Info("file stats", slog.String("file", f.Name), slog.Int("accepted", f.Accepted), slog.Int("discarded", f.Discarded))
+			}
+		}
+		if stats != nil && stats.Diff != nil {
+			p.log.Info("dry run diff", slog.Any("diff", *stats.Diff))
 		}
 
 		ec <- err
@@ -85,32 +138,59 @@ func (p *program) run() error {
 	return nil
 }
 
-// pgxLogger prints pgx logs to the standard logger.
-// os.Stderr by default.
-type pgxLogger struct {
-	log *slog.Logger
+// expandFiles to import from the file names, glob patterns, or - for the standard input, keeping their order.
+// The files matching a pattern are sorted by name.
+func expandFiles(patterns []string) ([]vio.ImportFile, error) {
+	var files []vio.ImportFile
+	for _, pattern := range patterns {
+		if pattern == "-" {
+			files = append(files, vio.ImportFile{
+				Name: "stdin",
+				Open: func() (io.ReadCloser, error) {
+					return decompress.NewReader(os.Stdin)
+				},
+			})
+			continue
+		}
+		names := []string{pattern}
+		if strings.ContainsAny(pattern, `*?[\`) {
+			var err error
+			if names, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("no files match %q", pattern)
+			}
+		}
+		for _, name := range names {
+			files = append(files, vio.ImportFile{
+				Name: filepath.Clean(name),
+				Open: func() (io.ReadCloser, error) {
+					return decompress.Open(name)
+				},
+			})
+		}
+	}
+	return files, nil
 }
 
-func (l pgxLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
-	attrs := make([]slog.Attr, 0, len(data)+1)
-	attrs = append(attrs, slog.String("pgx_level", level.String()))
-	for k, v := range data {
-		attrs = append(attrs, slog.Any(k, v))
+// sourceName of the data dump, from the paths of the files and patterns imported, so files of different directories don't collide.
+// Resuming an import requires the same name, so it doesn't depend on the files matching the patterns.
+func sourceName(patterns []string) string {
+	names := make([]string, len(patterns))
+	for n, pattern := range patterns {
+		names[n] = filepath.Clean(pattern)
+		if pattern == "-" {
+			names[n] = "stdin"
+		}
 	}
-	l.log.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+	return strings.Join(names, ",")
 }
 
-// slogLevel translates pgx log level to slog log level.
-func slogLevel(level tracelog.LogLevel) slog.Level {
-	switch level {
-	case tracelog.LogLevelTrace, tracelog.LogLevelDebug:
-		return slog.LevelDebug
-	case tracelog.LogLevelInfo:
-		return slog.LevelInfo
-	case tracelog.LogLevelWarn:
-		return slog.LevelWarn
-	default:
-		// If tracelog.LogLevelError, tracelog.LogLevelNone, or any other unknown level, use slog.LevelError.
-		return slog.LevelError
+// operator running the import.
+func operator() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
 	}
+	return "cli"
 }
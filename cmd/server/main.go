@@ -4,109 +4,255 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
+	"strconv"
+	"sync/atomic"
 	"syscall"
-	"time"
 
 	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/admin"
 	"github.com/henvic/vio/internal/api"
+	"github.com/henvic/vio/internal/config"
+	"github.com/henvic/vio/internal/logging"
+	"github.com/henvic/vio/internal/ratelimit"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/tracelog"
 )
 
-var httpAddr = flag.String("http", "localhost:8080", "HTTP service address to listen for incoming requests on")
-
 func main() {
-	flag.Parse()
+	conf, err := config.Load(flag.CommandLine, config.Server, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	level := new(slog.LevelVar)
+	log, closer, err := logging.New(conf.Log, level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(log)
 	p := program{
-		log: slog.Default(),
+		log:   log,
+		level: level,
 	}
+	p.config.Store(conf)
 
-	if err := p.run(); err != nil {
+	err = p.run()
+	if err != nil {
 		p.log.Error("application terminated", slog.Any("error", err))
+	}
+	closer.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
 
 type program struct {
-	log *slog.Logger
+	log   *slog.Logger
+	level *slog.LevelVar
+
+	// config currently in use, updated when reloaded.
+	config atomic.Pointer[config.Config]
 }
 
 func (p *program) run() error {
+	conf := p.config.Load()
+
 	// Using environment variables instead of a connection string.
 	// Reference for PostgreSQL environment variables:
 	// https://www.postgresql.org/docs/current/libpq-envars.html
-	conf, err := pgxpool.ParseConfig("")
+	pgConf, err := pgxpool.ParseConfig("")
 	if err != nil {
 		return err
 	}
 
-	conf.ConnConfig.Tracer = &tracelog.TraceLog{
-		Logger:   pgxLogger{log: p.log},
-		LogLevel: tracelog.LogLevelError,
+	if pgConf.ConnConfig.Tracer, err = logging.NewTracer(p.log, conf.Log.PgxLevel); err != nil {
+		return err
+	}
+	if t := conf.Server.DBStatementTimeout; t > 0 {
+		pgConf.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(t.Milliseconds(), 10)
 	}
 
-	db, err := pgxpool.NewWithConfig(context.Background(), conf)
+	db, err := pgxpool.NewWithConfig(context.Background(), pgConf)
 	if err != nil {
 		return fmt.Errorf("pgx pool connection error: %w", err)
 	}
 
 	defer db.Close()
 
-	s := api.NewServer(*httpAddr, vio.NewService(vio.NewPostgres(db, p.log)), p.log)
-	ec := make(chan error, 1)
+	opts, err := apiOptions(conf.Server)
+	if err != nil {
+		return err
+	}
+
+	pg := vio.NewPostgres(db, p.log)
+	if conf.Server.UsageFlushInterval > 0 {
+		opts.Usage = vio.NewUsageTracker(pg, p.log)
+		usageCtx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			opts.Usage.Run(usageCtx, conf.Server.UsageFlushInterval)
+			close(done)
+		}()
+		// Save pending usage counters after the server stops.
+		defer func() {
+			cancel()
+			<-done
+		}()
+	}
+
+	s := api.NewServer(conf.Server.HTTP, vio.NewService(pg), p.log, opts)
+	servers := []server{s}
+	if conf.Server.AdminAddr != "" {
+		servers = append(servers, admin.NewServer(conf.Server.AdminAddr, db, p.effectiveConfig(pgConf), p.log))
+	}
+
+	ec := make(chan error, len(servers))
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	for _, s := range servers {
+		go func(s server) {
+			ec <- s.Run(context.Background())
+		}(s)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 	go func() {
-		ec <- s.Run(context.Background())
+		for range hup {
+			p.reload(s)
+		}
 	}()
 
-	// Waits for an internal error that shutdowns the server.
-	// Otherwise, wait for a SIGINT or SIGTERM and tries to shutdown the server gracefully.
+	// Waits for an internal error that shutdowns the servers.
+	// Otherwise, wait for a SIGINT or SIGTERM and tries to shutdown the servers gracefully.
 	// After a shutdown signal, HTTP requests taking longer than the specified grace period are forcibly closed.
+	running := len(servers)
 	select {
 	case err = <-ec:
+		running--
 	case <-ctx.Done():
 		fmt.Println()
-		haltCtx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-		defer cancel()
+	}
+	stop()
+	haltCtx, cancel := context.WithTimeout(context.Background(), conf.Server.ShutdownTimeout)
+	defer cancel()
+	for _, s := range servers {
 		s.Shutdown(haltCtx)
-		stop()
-		err = <-ec
 	}
+	for ; running > 0; running-- {
+		if e := <-ec; err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// reload the configuration on SIGHUP, applying the settings that can be changed without restarting:
+// log level, cache control, request timeout, trusted proxies, rate limit, and CORS.
+func (p *program) reload(s *api.Server) {
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	next, err := config.Load(fs, config.Server, os.Args[1:])
 	if err != nil {
-		return err
+		p.log.Error("cannot reload configuration", slog.Any("error", err))
+		return
+	}
+	opts, err := apiOptions(next.Server)
+	if err != nil {
+		p.log.Error("cannot reload configuration", slog.Any("error", err))
+		return
+	}
+
+	cur := p.config.Load()
+	applied := *cur
+	applied.Log.Level = next.Log.Level
+	applied.Server = cur.Server.Reloadable(next.Server)
+	if !reflect.DeepEqual(&applied, next) {
+		p.log.Warn("configuration changes that require a restart were ignored")
 	}
-	return nil
+
+	// The log level was validated by config.Load.
+	p.level.UnmarshalText([]byte(next.Log.Level))
+	s.Reload(opts)
+	p.config.Store(&applied)
+	p.log.Info("configuration reloaded")
 }
 
-// pgxLogger prints pgx logs to the standard logger.
-// os.Stderr by default.
-type pgxLogger struct {
-	log *slog.Logger
+// server started by the program.
+type server interface {
+	Run(ctx context.Context) error
+	Shutdown(ctx context.Context)
 }
 
-func (l pgxLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
-	attrs := make([]slog.Attr, 0, len(data)+1)
-	attrs = append(attrs, slog.String("pgx_level", level.String()))
-	for k, v := range data {
-		attrs = append(attrs, slog.Any(k, v))
+// redacted replaces secrets on the effective configuration.
+const redacted = "REDACTED"
+
+// effectiveConfig of the program for the admin server, with secrets redacted.
+func (p *program) effectiveConfig(pgConf *pgxpool.Config) func() any {
+	return func() any {
+		dump, err := p.config.Load().Dump()
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		cc := pgConf.ConnConfig
+		database := map[string]any{
+			"host":      cc.Host,
+			"port":      cc.Port,
+			"database":  cc.Database,
+			"user":      cc.User,
+			"max_conns": pgConf.MaxConns,
+		}
+		if cc.Password != "" {
+			database["password"] = redacted
+		}
+		dump["database"] = database
+		return dump
 	}
-	l.log.LogAttrs(ctx, slogLevel(level), msg, attrs...)
 }
 
-// slogLevel translates pgx log level to slog log level.
-func slogLevel(level tracelog.LogLevel) slog.Level {
-	switch level {
-	case tracelog.LogLevelTrace, tracelog.LogLevelDebug:
-		return slog.LevelDebug
-	case tracelog.LogLevelInfo:
-		return slog.LevelInfo
-	case tracelog.LogLevelWarn:
-		return slog.LevelWarn
-	default:
-		// If tracelog.LogLevelError, tracelog.LogLevelNone, or any other unknown level, use slog.LevelError.
-		return slog.LevelError
+// apiOptions from the server configuration.
+func apiOptions(c config.ServerConfig) (opts api.Options, err error) {
+	opts.CacheControl = c.CacheControl
+	opts.ReadHeaderTimeout = c.ReadHeaderTimeout
+	opts.RequestTimeout = c.RequestTimeout
+	opts.RequireAuth = c.RequireAuth
+	if c.RateLimit.Rate > 0 {
+		opts.RateLimit = &api.RateLimit{
+			Limit: ratelimit.Limit{
+				Rate:  c.RateLimit.Rate,
+				Burst: c.RateLimit.Burst,
+			},
+			Store: ratelimit.NewMemory(),
+		}
+	}
+	if len(c.CORS.Origins) > 0 {
+		opts.CORS = &api.CORS{
+			AllowedOrigins: c.CORS.Origins,
+			AllowedMethods: c.CORS.Methods,
+			AllowedHeaders: c.CORS.Headers,
+			MaxAge:         c.CORS.MaxAge,
+		}
+	}
+	if c.TLS.Cert != "" {
+		opts.TLS = &api.TLS{
+			CertFile:       c.TLS.Cert,
+			KeyFile:        c.TLS.Key,
+			ClientCAFile:   c.TLS.ClientCA,
+			ReloadInterval: c.TLS.ReloadInterval,
+		}
+	}
+	if c.Compression.Enabled {
+		opts.Compression = &api.Compression{
+			MinSize: c.Compression.MinSize,
+		}
+	}
+	if opts.TrustedProxies, err = c.ParseTrustedProxies(); err != nil {
+		return opts, fmt.Errorf("invalid trusted proxies: %w", err)
 	}
+	return opts, nil
 }
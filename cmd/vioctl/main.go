@@ -0,0 +1,341 @@
+// Command vioctl manages the vio service.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/config"
+	"github.com/henvic/vio/internal/logging"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `usage: vioctl [flags] <command> [arguments]
+
+commands:
+  apikey create -name <name> -scopes <scope,...> [-monthly-quota <lookups>]
+                                       create an API key
+  apikey list                          list API keys
+  apikey revoke <id>                   revoke an API key
+  apikey quota <id> <lookups|unlimited>
+                                       set the monthly quota of an API key
+  override set -reason <reason> [-country-code <code>] [-country <name>] [-city <name>]
+               [-latitude <lat> -longitude <lon>] <ip>
+                                       override the geolocation of an IP address
+  override list                        list overrides
+  override delete <ip>                 delete an override, restoring the vendor data
+  import list                          list import runs
+  import rollback <id>                 revert the geolocations last changed by an import run
+
+flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	conf, err := config.Load(flag.CommandLine, config.CLI, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	log, closer, err := logging.New(conf.Log, new(slog.LevelVar))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(log)
+	p := program{
+		log:    log,
+		config: conf,
+	}
+
+	err = p.run(flag.Args())
+	if err != nil && err != flag.ErrHelp {
+		fmt.Fprintf(os.Stderr, "vioctl: %v\n", err)
+	}
+	closer.Close()
+	switch {
+	case err == flag.ErrHelp:
+		os.Exit(0)
+	case errors.Is(err, errUsage):
+		flag.Usage()
+		os.Exit(2)
+	case err != nil:
+		os.Exit(1)
+	}
+}
+
+// errUsage is returned when the command is invoked incorrectly.
+var errUsage = errors.New("invalid usage")
+
+type program struct {
+	log     *slog.Logger
+	config  *config.Config
+	service *vio.Service
+}
+
+func (p *program) run(args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	var cmd func(ctx context.Context, args []string) error
+	switch args[0] + " " + args[1] {
+	case "apikey create":
+		cmd = p.createAPIKey
+	case "apikey list":
+		cmd = p.listAPIKeys
+	case "apikey revoke":
+		cmd = p.revokeAPIKey
+	case "apikey quota":
+		cmd = p.setAPIKeyQuota
+	case "override set":
+		cmd = p.setOverride
+	case "override list":
+		cmd = p.listOverrides
+	case "override delete":
+		cmd = p.deleteOverride
+	case "import list":
+		cmd = p.listImportRuns
+	case "import rollback":
+		cmd = p.rollbackImportRun
+	default:
+		return errUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Using environment variables instead of a connection string.
+	// Reference for PostgreSQL environment variables:
+	// https://www.postgresql.org/docs/current/libpq-envars.html
+	conf, err := pgxpool.ParseConfig("")
+	if err != nil {
+		return err
+	}
+
+	if conf.ConnConfig.Tracer, err = logging.NewTracer(p.log, p.config.Log.PgxLevel); err != nil {
+		return err
+	}
+
+	db, err := pgxpool.NewWithConfig(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("pgx pool connection error: %w", err)
+	}
+	defer db.Close()
+
+	p.service = vio.NewService(vio.NewPostgres(db, p.log))
+	return cmd(ctx, args[2:])
+}
+
+func (p *program) createAPIKey(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	name := fs.String("name", "", "Name of the team or client using the API key")
+	scopes := fs.String("scopes", vio.ScopeLookup, "Comma-separated list of scopes: "+strings.Join(vio.Scopes, ", "))
+	quota := fs.String("monthly-quota", "unlimited", "Maximum number of lookups per month")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	monthlyQuota, err := parseQuota(*quota)
+	if err != nil {
+		return err
+	}
+	key, apiKey, err := p.service.CreateAPIKey(ctx, *name, strings.Split(*scopes, ","), monthlyQuota)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created API key %d (%s) with scopes %s.\n", apiKey.ID, apiKey.Name, strings.Join(apiKey.Scopes, ","))
+	fmt.Fprintln(os.Stderr, "Store it safely: it cannot be retrieved again.")
+	fmt.Println(key)
+	return nil
+}
+
+func (p *program) listAPIKeys(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	keys, err := p.service.ListAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tMONTHLY QUOTA\tCREATED\tREVOKED")
+	for _, k := range keys {
+		quota, revoked := "unlimited", "-"
+		if k.MonthlyQuota != nil {
+			quota = strconv.FormatInt(*k.MonthlyQuota, 10)
+		}
+		if k.RevokedAt != nil {
+			revoked = k.RevokedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), quota, k.CreatedAt.Format(time.RFC3339), revoked)
+	}
+	return tw.Flush()
+}
+
+func (p *program) revokeAPIKey(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID: %w", err)
+	}
+	if err := p.service.RevokeAPIKey(ctx, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Revoked API key %d.\n", id)
+	return nil
+}
+
+func (p *program) setAPIKeyQuota(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid API key ID: %w", err)
+	}
+	monthlyQuota, err := parseQuota(args[1])
+	if err != nil {
+		return err
+	}
+	if err := p.service.SetAPIKeyQuota(ctx, id, monthlyQuota); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Set monthly quota of API key %d to %s.\n", id, args[1])
+	return nil
+}
+
+func (p *program) setOverride(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("override set", flag.ContinueOnError)
+	countryCode := fs.String("country-code", "", "Uppercase 2-letter ISO 3166-1 country code")
+	country := fs.String("country", "", "Country name")
+	city := fs.String("city", "", "City name")
+	latitude := fs.String("latitude", "", "Latitude")
+	longitude := fs.String("longitude", "", "Longitude")
+	reason := fs.String("reason", "", "Reason for the override, e.g., a support ticket")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	o, err := p.service.SetOverride(ctx, fs.Arg(0), vio.Override{
+		CountryCode: *countryCode,
+		Country:     *country,
+		City:        *city,
+		Latitude:    json.Number(*latitude),
+		Longitude:   json.Number(*longitude),
+		Reason:      *reason,
+		Author:      author(),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Overrode geolocation of %s.\n", o.IPAddress)
+	return nil
+}
+
+func (p *program) listOverrides(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	overrides, err := p.service.ListOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IP ADDRESS\tCOUNTRY CODE\tCOUNTRY\tCITY\tLATITUDE\tLONGITUDE\tREASON\tAUTHOR\tUPDATED")
+	for _, o := range overrides {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.IPAddress, o.CountryCode, o.Country, o.City,
+			o.Latitude, o.Longitude, o.Reason, o.Author, o.UpdatedAt.Format(time.RFC3339))
+	}
+	return tw.Flush()
+}
+
+func (p *program) deleteOverride(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if err := p.service.DeleteOverride(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Deleted override of %s.\n", args[0])
+	return nil
+}
+
+func (p *program) listImportRuns(ctx context.Context, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	runs, err := p.service.ListImportRuns(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSOURCE\tMODE\tSTATUS\tACCEPTED\tDISCARDED\tPRUNED\tOPERATOR\tSTARTED\tFINISHED\tCHECKSUM")
+	for _, r := range runs {
+		finished, checksum := "-", "-"
+		if r.FinishedAt != nil {
+			finished = r.FinishedAt.Format(time.RFC3339)
+		}
+		if r.Checksum != "" {
+			checksum = r.Checksum
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\t%s\n", r.ID, r.Source, r.Mode, r.Status, r.Accepted, r.Discarded, r.Pruned,
+			r.Operator, r.StartedAt.Format(time.RFC3339), finished, checksum)
+	}
+	return tw.Flush()
+}
+
+func (p *program) rollbackImportRun(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid import run ID: %w", err)
+	}
+	rollback, err := p.service.RollbackImportRun(ctx, id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Rolled back import run %d: restored %d, deleted %d, and unpruned %d geolocations.\n",
+		id, rollback.Restored, rollback.Deleted, rollback.Unpruned)
+	return nil
+}
+
+// author of changes made with the CLI.
+func author() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// parseQuota parses a number of lookups or "unlimited".
+func parseQuota(s string) (*int64, error) {
+	if s == "unlimited" {
+		return nil, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quota: %w", err)
+	}
+	return &n, nil
+}
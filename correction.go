@@ -0,0 +1,108 @@
+package vio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"unicode/utf8"
+)
+
+// Statuses of corrections.
+const (
+	// CorrectionPending is waiting for review.
+	CorrectionPending = "pending"
+
+	// CorrectionApproved was turned into an override.
+	CorrectionApproved = "approved"
+
+	// CorrectionRejected was discarded.
+	CorrectionRejected = "rejected"
+)
+
+// maxCorrectionComment is the maximum length of the comment of a correction, in characters.
+const maxCorrectionComment = 1000
+
+// Correction of the geolocation of an IP address proposed by a client of the API.
+// Approved corrections become overrides.
+type Correction struct {
+	ID          int64
+	IPAddress   net.IP
+	CountryCode string
+	Country     string
+	City        string
+	Latitude    json.Number
+	Longitude   json.Number
+
+	// Comment of the reporter, e.g., how the location is known.
+	Comment string
+
+	// Reporter is the client that proposed the correction: key:<api key ID> or ip:<address>.
+	Reporter string
+
+	Status    string
+	CreatedAt time.Time
+
+	// Reviewer is who approved or rejected the correction.
+	Reviewer   *string
+	ReviewedAt *time.Time
+}
+
+// ErrCorrectionNotFound is returned when a correction doesn't exist.
+var ErrCorrectionNotFound = errors.New("correction not found")
+
+// ErrInvalidCorrectionStatus is returned when filtering corrections by an unknown status.
+var ErrInvalidCorrectionStatus = errors.New("invalid correction status")
+
+// ErrCorrectionReviewed is returned when trying to review a correction that was already approved or rejected.
+var ErrCorrectionReviewed = errors.New("correction was already reviewed")
+
+// SubmitCorrection adds a correction of the geolocation of an IP address to the moderation queue.
+func (s *Service) SubmitCorrection(ctx context.Context, ip string, c Correction) (*Correction, error) {
+	c.IPAddress = net.ParseIP(ip)
+	if c.IPAddress == nil {
+		return nil, ErrBadIPAddressFormat
+	}
+	loc := Geolocation{
+		IPAddress:   c.IPAddress,
+		CountryCode: c.CountryCode,
+		Country:     c.Country,
+		City:        c.City,
+		Latitude:    c.Latitude,
+		Longitude:   c.Longitude,
+	}
+	if err := loc.Validate(); err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(c.Comment) > maxCorrectionComment {
+		return nil, fmt.Errorf("%w: comment is longer than %d characters", ErrInvalidGeolocation, maxCorrectionComment)
+	}
+	c.Status = CorrectionPending
+	c.Reviewer, c.ReviewedAt = nil, nil
+	if err := s.db.CreateCorrection(ctx, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListCorrections returns the corrections with the given status, or all corrections if status is empty.
+func (s *Service) ListCorrections(ctx context.Context, status string) ([]Correction, error) {
+	switch status {
+	case "", CorrectionPending, CorrectionApproved, CorrectionRejected:
+	default:
+		return nil, ErrInvalidCorrectionStatus
+	}
+	return s.db.ListCorrections(ctx, status)
+}
+
+// ApproveCorrection approves a pending correction, creating an override from it.
+func (s *Service) ApproveCorrection(ctx context.Context, id int64, reviewer string) (*Correction, error) {
+	return s.db.ReviewCorrection(ctx, id, CorrectionApproved, reviewer)
+}
+
+// RejectCorrection rejects a pending correction.
+func (s *Service) RejectCorrection(ctx context.Context, id int64, reviewer string) (*Correction, error) {
+	return s.db.ReviewCorrection(ctx, id, CorrectionRejected, reviewer)
+}
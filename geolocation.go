@@ -1,9 +1,16 @@
 package vio
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // Geolocation data.
@@ -15,4 +22,187 @@ type Geolocation struct {
 	Latitude    json.Number
 	Longitude   json.Number
 	UpdatedAt   time.Time
+
+	// Source of the geolocation data: SourceVendor or SourceOverride.
+	Source string
+
+	// RunID of the import run that last changed the vendor data. Nil for overrides and data edited manually.
+	RunID *int64
+
+	// Dataset is the name of the data dump imported by the import run, if any.
+	Dataset string
+}
+
+// GeolocationVersion of the geolocation of an IP address during a period of time.
+type GeolocationVersion struct {
+	IPAddress   net.IP
+	CountryCode string
+	Country     string
+	City        string
+	Latitude    json.Number
+	Longitude   json.Number
+	Source      string
+
+	// RunID of the import run that changed the vendor data. Nil for overrides and data edited manually.
+	RunID *int64
+
+	ValidFrom time.Time
+
+	// ValidTo is when the version was replaced or deleted. Nil for the current version.
+	ValidTo *time.Time
+}
+
+// Sources of geolocation data.
+const (
+	// SourceVendor is the data imported from the vendor data dump.
+	SourceVendor = "vendor"
+
+	// SourceOverride is a manual correction, which takes precedence over the vendor data.
+	SourceOverride = "override"
+)
+
+// ErrInvalidGeolocation is returned when geolocation data fails validation.
+var ErrInvalidGeolocation = errors.New("invalid geolocation")
+
+// Validate the geolocation data.
+func (loc Geolocation) Validate() error {
+	if len(loc.IPAddress) == 0 {
+		return fmt.Errorf("%w: missing IP address", ErrInvalidGeolocation)
+	}
+	if loc.City == "" && loc.Country == "" && loc.CountryCode == "" &&
+		loc.Latitude == "" && loc.Longitude == "" {
+		return fmt.Errorf("%w: no useful data found", ErrInvalidGeolocation)
+	}
+	if loc.CountryCode != "" && !isCountryCode(loc.CountryCode) {
+		return fmt.Errorf("%w: country code must be an uppercase 2-letter ISO 3166-1 code", ErrInvalidGeolocation)
+	}
+	return validateCoordinates(loc.Latitude, loc.Longitude)
+}
+
+// validateCoordinates checks that latitude and longitude are both set and within range, or both empty.
+func validateCoordinates(lat, lon json.Number) error {
+	if lat == "" && lon == "" {
+		return nil
+	}
+	if !isCoordinate(string(lat), 90) {
+		return fmt.Errorf("%w: latitude must be a number between -90 and 90", ErrInvalidGeolocation)
+	}
+	if !isCoordinate(string(lon), 180) {
+		return fmt.Errorf("%w: longitude must be a number between -180 and 180", ErrInvalidGeolocation)
+	}
+	return nil
+}
+
+// isCoordinate checks if the given string is a number in the [-limit, limit] range.
+func isCoordinate(v string, limit float64) bool {
+	f, err := strconv.ParseFloat(v, 64)
+	return err == nil && f >= -limit && f <= limit
+}
+
+// isCountryCode naively checks if the given string is an uppercase 2-letter ISO 3166-1 country code.
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, c := range code {
+		if !unicode.IsUpper(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// GeolocationPatch with the fields to change on geolocation records. Nil fields are left unchanged.
+type GeolocationPatch struct {
+	CountryCode *string
+	Country     *string
+	City        *string
+	Latitude    *json.Number
+	Longitude   *json.Number
+}
+
+// Validate the changes.
+func (p GeolocationPatch) Validate() error {
+	if p.CountryCode == nil && p.Country == nil && p.City == nil && p.Latitude == nil && p.Longitude == nil {
+		return fmt.Errorf("%w: no changes", ErrInvalidGeolocation)
+	}
+	if p.CountryCode != nil && *p.CountryCode != "" && !isCountryCode(*p.CountryCode) {
+		return fmt.Errorf("%w: country code must be an uppercase 2-letter ISO 3166-1 code", ErrInvalidGeolocation)
+	}
+	if (p.Latitude == nil) != (p.Longitude == nil) {
+		return fmt.Errorf("%w: latitude and longitude must be changed together", ErrInvalidGeolocation)
+	}
+	if p.Latitude != nil {
+		return validateCoordinates(*p.Latitude, *p.Longitude)
+	}
+	return nil
+}
+
+// ErrBadNetworkFormat is returned when using a bad IP address or CIDR network format.
+var ErrBadNetworkFormat = errors.New("invalid IP address or CIDR network format")
+
+// ParseNetwork parses an IP address or a CIDR network, e.g., 192.0.2.0/24.
+// The network address must not have bits set beyond the prefix length.
+func ParseNetwork(s string) (netip.Prefix, error) {
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil || addr.Zone() != "" {
+			return netip.Prefix{}, ErrBadNetworkFormat
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil || p != p.Masked() {
+		return netip.Prefix{}, ErrBadNetworkFormat
+	}
+	return p, nil
+}
+
+// PutLocation creates or replaces the geolocation of an IP address.
+// It returns the stored geolocation and whether it was created.
+func (s *Service) PutLocation(ctx context.Context, ip string, loc Geolocation) (*Geolocation, bool, error) {
+	loc.IPAddress = net.ParseIP(ip)
+	if loc.IPAddress == nil {
+		return nil, false, ErrBadIPAddressFormat
+	}
+	if err := loc.Validate(); err != nil {
+		return nil, false, err
+	}
+	created, err := s.db.PutLocation(ctx, &loc)
+	if err != nil {
+		return nil, false, err
+	}
+	return &loc, created, nil
+}
+
+// UpdateLocations changes the geolocations of an IP address or of all IP addresses within a CIDR network.
+// It returns the number of geolocations changed.
+func (s *Service) UpdateLocations(ctx context.Context, network string, patch GeolocationPatch) (int64, error) {
+	p, err := ParseNetwork(network)
+	if err != nil {
+		return 0, err
+	}
+	if err := patch.Validate(); err != nil {
+		return 0, err
+	}
+	n, err := s.db.UpdateLocations(ctx, p, patch)
+	if err == nil && n == 0 {
+		err = ErrLocationNotFound
+	}
+	return n, err
+}
+
+// DeleteLocations deletes the geolocations of an IP address or of all IP addresses within a CIDR network.
+// It returns the number of geolocations deleted.
+func (s *Service) DeleteLocations(ctx context.Context, network string) (int64, error) {
+	p, err := ParseNetwork(network)
+	if err != nil {
+		return 0, err
+	}
+	n, err := s.db.DeleteLocations(ctx, p)
+	if err == nil && n == 0 {
+		err = ErrLocationNotFound
+	}
+	return n, err
 }
@@ -0,0 +1,88 @@
+package vio_test
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/henvic/vio"
+)
+
+func TestGeolocationValidate(t *testing.T) {
+	t.Parallel()
+	ip := net.ParseIP("192.0.2.1")
+	tests := []struct {
+		name    string
+		loc     vio.Geolocation
+		wantErr string
+	}{
+		{
+			name: "valid",
+			loc:  vio.Geolocation{IPAddress: ip, CountryCode: "NL", City: "Amsterdam", Latitude: "52.37", Longitude: "4.89"},
+		},
+		{
+			name:    "missing_ip",
+			loc:     vio.Geolocation{CountryCode: "NL"},
+			wantErr: "invalid geolocation: missing IP address",
+		},
+		{
+			name:    "empty",
+			loc:     vio.Geolocation{IPAddress: ip},
+			wantErr: "invalid geolocation: no useful data found",
+		},
+		{
+			name:    "country_code",
+			loc:     vio.Geolocation{IPAddress: ip, CountryCode: "NLD"},
+			wantErr: "invalid geolocation: country code must be an uppercase 2-letter ISO 3166-1 code",
+		},
+		{
+			name:    "latitude",
+			loc:     vio.Geolocation{IPAddress: ip, Latitude: "91", Longitude: "0"},
+			wantErr: "invalid geolocation: latitude must be a number between -90 and 90",
+		},
+		{
+			name:    "longitude_missing",
+			loc:     vio.Geolocation{IPAddress: ip, Latitude: "10"},
+			wantErr: "invalid geolocation: longitude must be a number between -180 and 180",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.loc.Validate()
+			if err == nil && tt.wantErr != "" || err != nil && err.Error() != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, vio.ErrInvalidGeolocation) {
+				t.Errorf("Validate() error should be ErrInvalidGeolocation")
+			}
+		})
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in      string
+		want    netip.Prefix
+		wantErr bool
+	}{
+		{in: "192.0.2.1", want: netip.MustParsePrefix("192.0.2.1/32")},
+		{in: "::ffff:192.0.2.1", want: netip.MustParsePrefix("192.0.2.1/32")},
+		{in: "2001:db8::/32", want: netip.MustParsePrefix("2001:db8::/32")},
+		{in: "192.0.2.0/24", want: netip.MustParsePrefix("192.0.2.0/24")},
+		{in: "192.0.2.1/24", wantErr: true},
+		{in: "fe80::1%eth0", wantErr: true},
+		{in: "example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := vio.ParseNetwork(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseNetwork(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseNetwork(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -6,7 +6,9 @@ require (
 	github.com/google/go-cmp v0.6.0
 	github.com/henvic/pgtools v0.2.0
 	github.com/jackc/pgx/v5 v5.6.0
+	github.com/klauspost/compress v1.18.0
 	go.uber.org/mock v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
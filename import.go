@@ -2,6 +2,7 @@ package vio
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -9,47 +10,187 @@ import (
 	"io"
 	"log/slog"
 	"net"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
-	"unicode"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ImportStats of the geolocation ingestion.
 type ImportStats struct {
+	RunID       int64
 	TimeElapsed time.Duration
 	Accepted    int
 	Discarded   int
+
+	// Pruned geolocations missing from the data dump of a sync import,
+	// or the ones that would be pruned on a dry run.
+	Pruned int
+
+	// Mapping of the fields to the columns (starting at 1) they were found on, with the number of accepted records.
+	Mapping map[string]map[int]int
+
+	// Diff of the data dump against the current geolocations. Only set on a dry run.
+	Diff *ImportDiff
+
+	// Retries after transient database errors, and the number of batches retried.
+	Retries        int
+	RetriedBatches int
+
+	// Files has the stats of each file of the data dump.
+	Files []ImportFileStats
+}
+
+// ImportFileStats of a file of the data dump.
+// Files and records skipped when resuming an import run aren't counted.
+type ImportFileStats struct {
+	Name      string
+	Accepted  int
+	Discarded int
+}
+
+// ImportDiff of a data dump against the current geolocations, in number of IP addresses.
+// Only the last record of an IP address is compared, as it is the one imported.
+type ImportDiff struct {
+	New       int
+	Changed   int
+	Unchanged int
+
+	// Removed is the number of geolocations missing from the data dump, which a sync import prunes.
+	Removed int
+}
+
+// Import modes.
+const (
+	// ImportUpsert creates or updates the geolocations of the data dump, keeping the others.
+	ImportUpsert = "upsert"
+
+	// ImportSync creates or updates the geolocations of the data dump, and prunes the ones missing from it.
+	ImportSync = "sync"
+)
+
+// ImportOptions for the importer.
+type ImportOptions struct {
+	// BatchSize is the number of records to be inserted in a single batch.
+	BatchSize int
+
+	// Workers is the number of batches written concurrently (default 1).
+	Workers int
+
+	// Mode of the import: ImportUpsert (default) or ImportSync.
+	Mode string
+
+	// MaxPruneRatio is the maximum fraction of the geolocations a sync import may prune (0 disables the limit).
+	MaxPruneRatio float64
+
+	// DryRun parses the data dump and compares it with the current geolocations without writing anything.
+	DryRun bool
+
+	// Rejects receives the records that were discarded as CSV, with their line and the reason, if set.
+	Rejects io.Writer
+
+	// Thresholds that abort the import before it changes the geolocations.
+	Thresholds ImportThresholds
+
+	// Retry of batches that fail with transient database errors.
+	Retry ImportRetry
+
+	// Resume the last import run of the data dump if it failed, continuing from its checkpoint.
+	// The input must start with the data read up to the checkpoint, which is skipped.
+	// Mapping only has the records read after the checkpoint.
+	Resume bool
+}
+
+// ErrPruneLimit is returned when a sync import would prune more geolocations than allowed.
+var ErrPruneLimit = errors.New("too many geolocations to prune")
+
+// ImportSource describes the data dump being imported.
+type ImportSource struct {
+	// Name of the data dump, e.g., its file name.
+	Name string
+
+	// Operator running the import, e.g., cli:<user>.
+	Operator string
+}
+
+// ImportFile of a data dump. Files are opened when they are imported.
+type ImportFile struct {
+	Name string
+	Open func() (io.ReadCloser, error)
 }
 
 // NewImporter creates a new CSV reader.
-func NewImporter(batchSize int, log *slog.Logger, db *pgxpool.Pool) *Importer {
+func NewImporter(log *slog.Logger, db *pgxpool.Pool, opts ImportOptions) *Importer {
+	if opts.Mode == "" {
+		opts.Mode = ImportUpsert
+	}
+	opts.Workers = max(opts.Workers, 1)
+	opts.Retry.MaxAttempts = max(opts.Retry.MaxAttempts, 1)
+	if opts.Retry.Backoff <= 0 {
+		opts.Retry.Backoff = 100 * time.Millisecond
+	}
+	if opts.Retry.MaxBackoff <= 0 {
+		opts.Retry.MaxBackoff = 10 * time.Second
+	}
+	opts.Retry.MaxBackoff = max(opts.Retry.MaxBackoff, opts.Retry.Backoff)
 	return &Importer{
-		batchSize: batchSize,
-		log:       log,
-		db:        db,
+		opts: opts,
+		log:  log,
+		pool: db,
+		db:   db,
 	}
 }
 
 // Importer for the CSV stream.
 type Importer struct {
-	// batchSize is the number of records to be inserted in a single batch.
-	batchSize int
+	// opts of the import.
+	opts ImportOptions
 
 	// log for the importer.
 	log *slog.Logger
 
 	// pool for accessing Postgres database.PGX
-	db *pgxpool.Pool
+	pool *pgxpool.Pool
+
+	// db used by the import: the pool, or the connection of a dry run.
+	db importDB
+}
+
+// importDB is a pool or connection of the database.
+type importDB interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 }
 
 // Stream imports data from CSV input and stream it to database.
+// The import is recorded as an import run, and the imported records reference it.
+// Records are staged in batches, and then applied to the geolocations in a single transaction
+// if they are within the thresholds. A sync import also prunes the geolocations missing from the data dump.
+// A checkpoint is saved as batches are staged, and the staged records of a failed import run are kept,
+// so it can be resumed. A dry run only reads the database.
 //
 // Assume the typical CSV format is
 // ip_address,country_code,country,city,latitude,longitude,mystery_value
-func (i *Importer) Stream(ctx context.Context, r io.Reader) (*ImportStats, error) {
+func (i *Importer) Stream(ctx context.Context, r io.Reader, src ImportSource) (*ImportStats, error) {
+	return i.StreamFiles(ctx, []ImportFile{{
+		Name: src.Name,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	}}, src)
+}
+
+// StreamFiles imports a data dump split into multiple files within a single import run, like Stream.
+// The files are read in order, and the checksum of the import run is of their concatenation.
+func (i *Importer) StreamFiles(ctx context.Context, files []ImportFile, src ImportSource) (*ImportStats, error) {
 	var (
 		stats ImportStats
 		begin = time.Now()
@@ -59,119 +200,685 @@ func (i *Importer) Stream(ctx context.Context, r io.Reader) (*ImportStats, error
 		stats.TimeElapsed = time.Since(begin)
 	}()
 
-	// Use batches to reduce round-trips.
+	if i.opts.Mode != ImportUpsert && i.opts.Mode != ImportSync {
+		return &stats, fmt.Errorf("invalid import mode: %q", i.opts.Mode)
+	}
+	if i.opts.DryRun {
+		return &stats, i.dryRun(ctx, files, &stats)
+	}
+
+	cps := &checkpoints{
+		input: &checksumReader{h: sha256.New()},
+		done:  map[int]importCheckpoint{},
+	}
+	if i.opts.Resume {
+		if err := i.resume(ctx, src, cps, &stats); err != nil {
+			return &stats, err
+		}
+	} else {
+		if err := i.start(ctx, src, &stats); err != nil {
+			return &stats, err
+		}
+		cps.runID = stats.RunID
+	}
+	// Staged records of a failed import run are kept until it is resumed, unless it can't be resumed.
+	var resumable bool
+	defer func() {
+		if !resumable {
+			i.discardStaged(context.WithoutCancel(ctx), `run_id = $1`, stats.RunID)
+		}
+	}()
+
+	err := i.stream(ctx, files, &stats, cps)
+	if err == nil {
+		err = i.apply(ctx, &stats)
+	}
+	// An import run failing before its first checkpoint is started over,
+	// and one exceeding the thresholds would exceed them again.
+	resumable = err != nil && cps.last.checksum != "" &&
+		!errors.Is(err, ErrImportThreshold) && !errors.Is(err, ErrPruneLimit)
+
+	// Record the outcome even if the import was canceled.
 	var (
-		batch       pgx.Batch
-		batchNumber int
-		total       int
+		status   = ImportRunSucceeded
+		sum      string
+		errorMsg string
 	)
+	if err != nil {
+		status, errorMsg = ImportRunFailed, err.Error()
+	} else {
+		sum = cps.input.sum()
+	}
+	const finishRunQuery = `UPDATE import_runs SET status = $2, checksum = $3, accepted = $4, discarded = $5, pruned = $6, error = $7,
+finished_at = now() WHERE id = $1`
+	if _, ferr := i.db.Exec(context.WithoutCancel(ctx), finishRunQuery,
+		stats.RunID, status, sum, stats.Accepted, stats.Discarded, stats.Pruned, errorMsg); ferr != nil {
+		i.log.Error("cannot finish import run", slog.Int64("run_id", stats.RunID), slog.Any("error", ferr))
+		if err == nil {
+			err = fmt.Errorf("cannot finish import run: %w", ferr)
+		}
+	}
+	return &stats, err
+}
 
-	stream := csv.NewReader(r)
-	stream.ReuseRecord = true
+// start an import run, discarding the records staged by previous failed import runs of the data dump.
+func (i *Importer) start(ctx context.Context, src ImportSource, stats *ImportStats) error {
+	i.discardStaged(ctx, `run_id IN (SELECT id FROM import_runs WHERE source = $1 AND status = 'failed')`, src.Name)
 
-	for {
-		if ctx.Err() != nil {
-			return &stats, ctx.Err()
+	const startRunQuery = `INSERT INTO import_runs (source, operator, mode) VALUES ($1, $2, $3) RETURNING id`
+	if err := i.db.QueryRow(ctx, startRunQuery, src.Name, src.Operator, i.opts.Mode).Scan(&stats.RunID); err != nil {
+		return fmt.Errorf("cannot start import run: %w", err)
+	}
+	i.log.Info("Import run started",
+		slog.Int64("run_id", stats.RunID),
+		slog.String("source", src.Name),
+		slog.String("mode", i.opts.Mode),
+	)
+	return nil
+}
+
+// discardStaged deletes the staged records and checkpoints of the import runs matching the condition.
+func (i *Importer) discardStaged(ctx context.Context, cond string, arg any) {
+	for _, table := range []string{"import_run_rows", "import_run_checkpoints"} {
+		if _, err := i.db.Exec(ctx, "DELETE FROM "+table+" WHERE "+cond, arg); err != nil {
+			i.log.Error("cannot delete records staged by import run", slog.String("table", table), slog.Any("error", err))
 		}
+	}
+}
 
-		record, err := stream.Read()
-		if err == io.EOF {
-			break
+// importBatch of accepted records, numbered from first in the order of the data dump.
+type importBatch struct {
+	number int
+	file   int
+	first  int64
+	locs   []Geolocation
+
+	// lines of the records, and the records themselves if they are needed for the rejects report.
+	lines   []int64
+	records [][]string
+
+	// checkpoint of the import run once the batch and the ones before it are staged.
+	checkpoint importCheckpoint
+}
+
+// slice of the records of the batch.
+func (b importBatch) slice(low, high int) importBatch {
+	s := importBatch{
+		number: b.number,
+		file:   b.file,
+		first:  b.first + int64(low),
+		locs:   b.locs[low:high],
+		lines:  b.lines[low:high],
+	}
+	if b.records != nil {
+		s.records = b.records[low:high]
+	}
+	return s
+}
+
+// stream the CSV records to the staging table in batches, referencing the import run,
+// saving a checkpoint as they are staged, if any. A resumed import run continues from its last checkpoint.
+//
+// The records are parsed on the calling goroutine, and the batches are written concurrently by the workers.
+// Parsing blocks while all workers are busy, and stops on the first error.
+// Batches failing with transient database errors are retried, and records rejected by the database are discarded.
+func (i *Importer) stream(ctx context.Context, files []ImportFile, stats *ImportStats, cps *checkpoints) (err error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Lines and offsets are relative to the checkpoint the import run was resumed from.
+	var base importCheckpoint
+	if cps != nil {
+		base = cps.last
+	}
+	resumed := base.checksum != ""
+	if resumed && base.file >= len(files) {
+		return fmt.Errorf("%w: checkpoint is on file %d, but there are only %d files", ErrCheckpointMismatch, base.file+1, len(files))
+	}
+
+	var (
+		batches      = make(chan importBatch, i.opts.Workers)
+		wg           sync.WaitGroup
+		total        atomic.Int64
+		retried      retryCount
+		rejected     atomic.Int64
+		fileRejected = make([]atomic.Int64, len(files))
+		rejects      *rejectsWriter
+	)
+	rejected.Store(int64(base.rejected))
+	if i.opts.Rejects != nil {
+		rejects = newRejectsWriter(i.opts.Rejects)
+	}
+	// Lines are prefixed with the name of the file when importing multiple files.
+	label := func(file int) string {
+		if len(files) == 1 {
+			return ""
+		}
+		return files[file].Name
+	}
+
+	for range i.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				var err error
+				b.checkpoint.rejected, err = i.stageBatch(ctx, b, label(b.file), stats.RunID, &retried, rejects)
+				if err == nil {
+					rejected.Add(int64(b.checkpoint.rejected))
+					fileRejected[b.file].Add(int64(b.checkpoint.rejected))
+				}
+				if err == nil && cps != nil {
+					err = i.retry(ctx, slog.Int("batch", b.number), &retried, func(int) error {
+						return cps.save(ctx, i.db, b.checkpoint)
+					})
+				}
+				if err != nil {
+					cancel(fmt.Errorf("batch %d error: %w", b.number, err))
+					return
+				}
+				i.log.Info("Batch processed",
+					slog.Int("batch", b.number),
+					slog.Any("total", total.Add(int64(len(b.locs)))),
+				)
+			}
+		}()
+	}
+	defer func() {
+		close(batches)
+		wg.Wait()
+		stats.Retries, stats.RetriedBatches = int(retried.retries.Load()), int(retried.batches.Load())
+		stats.Accepted -= int(rejected.Load())
+		stats.Discarded += int(rejected.Load())
+		for n := range stats.Files {
+			stats.Files[n].Accepted -= int(fileRejected[n].Load())
+			stats.Files[n].Discarded += int(fileRejected[n].Load())
+		}
+		if err == nil {
+			err = context.Cause(ctx)
 		}
-		if err != nil && err != csv.ErrFieldCount {
-			stats.Discarded++
-			continue
+		if ferr := rejects.flush(); err == nil && ferr != nil {
+			err = fmt.Errorf("cannot write rejects: %w", ferr)
 		}
+	}()
 
-		var loc Geolocation
-		if err := i.loadRecord(record, &loc); err != nil {
-			stats.Discarded++
-			continue
+	// Use batches to reduce round-trips. A batch only has records of a file.
+	var (
+		batch       importBatch
+		batchNumber = base.batch
+	)
+	stats.Mapping = map[string]map[int]int{}
+	stats.Files = make([]ImportFileStats, len(files))
+	for n, file := range files {
+		stats.Files[n].Name = file.Name
+	}
+
+	flush := func(file int, line, offset int64) error {
+		batchNumber++
+		batch.number = batchNumber
+		batch.file = file
+		batch.first = int64(stats.Accepted - len(batch.locs))
+		batch.checkpoint = importCheckpoint{
+			batch:     batchNumber,
+			file:      file,
+			line:      line,
+			offset:    offset,
+			accepted:  stats.Accepted,
+			discarded: stats.Discarded,
+		}
+		if cps != nil {
+			batch.checkpoint.checksum = cps.input.checksum()
 		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+		batch = importBatch{
+			locs:  make([]Geolocation, 0, i.opts.BatchSize),
+			lines: make([]int64, 0, i.opts.BatchSize),
+		}
+		return nil
+	}
 
+	// parse the records of a file. Lines and offsets start after the base ones.
+	parse := func(file int, r io.Reader, baseLine, baseOffset int64) error {
+		var (
+			fileStats = &stats.Files[file]
+			line      int64
+		)
+		stream := csv.NewReader(r)
+		stream.ReuseRecord = true
+
+		for {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+
+			record, err := stream.Read()
+			if cps != nil {
+				// Hash the input as the records are read, so only what the CSV reader read ahead is kept.
+				cps.input.advance(baseOffset + stream.InputOffset())
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil && err != csv.ErrFieldCount {
+				pe, ok := err.(*csv.ParseError)
+				if !ok {
+					return fmt.Errorf("cannot read %s: %w", files[file].Name, err)
+				}
+				stats.Discarded++
+				fileStats.Discarded++
+				line = baseLine + int64(pe.Line)
+				rejects.write(label(file), baseLine+int64(pe.StartLine), err, record)
+				continue
+			}
+
+			start, _ := stream.FieldPos(0)
+			line = baseLine + int64(start)
+
+			var loc Geolocation
+			mapping, err := i.loadRecord(record, &loc)
+			if err != nil {
+				stats.Discarded++
+				fileStats.Discarded++
+				rejects.write(label(file), line, err, record)
+				continue
+			}
+			for field, col := range mapping {
+				if col == 0 {
+					continue
+				}
+				if stats.Mapping[recordFields[field]] == nil {
+					stats.Mapping[recordFields[field]] = map[int]int{}
+				}
+				stats.Mapping[recordFields[field]][col]++
+			}
+
+			batch.locs = append(batch.locs, loc)
+			batch.lines = append(batch.lines, line)
+			if rejects != nil {
+				batch.records = append(batch.records, slices.Clone(record))
+			}
+			stats.Accepted++
+			fileStats.Accepted++
+
+			if len(batch.locs) == i.opts.BatchSize {
+				if err := flush(file, line, baseOffset+stream.InputOffset()); err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(batch.locs) > 0 {
+			return flush(file, line, baseOffset+stream.InputOffset())
+		}
+		return nil
+	}
+
+	for n, file := range files {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		r, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("cannot open %s: %w", file.Name, err)
+		}
+		var (
+			in         = io.Reader(r)
+			baseOffset int64
+		)
+		if cps != nil {
+			cps.input.r = r
+			in = cps.input
+			baseOffset = cps.input.read()
+		}
+		switch {
+		case resumed && n < base.file:
+			// Records up to the checkpoint were staged, but the input is read for the checksum.
+			err = cps.input.drain()
+		case resumed && n == base.file:
+			if err = cps.skip(base.offset - baseOffset); err == nil {
+				err = parse(n, in, base.line, base.offset)
+			}
+		default:
+			err = parse(n, in, 0, baseOffset)
+		}
+		if cerr := r.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("cannot close %s: %w", file.Name, cerr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stageBatch writes the batch to the staging table, retrying on transient database errors.
+// If the database rejects a record, the batch is bisected to stage the other records,
+// and the offending ones are written to the rejects report.
+func (i *Importer) stageBatch(ctx context.Context, b importBatch, file string, runID int64, count *retryCount, rejects *rejectsWriter) (rejected int, err error) {
+	err = i.retry(ctx, slog.Int("batch", b.number), count, func(attempt int) error {
+		return i.writeBatch(ctx, b.locs, runID, b.first, attempt > 1)
+	})
+	if err == nil || !isRecordError(err) {
+		return 0, err
+	}
+	if len(b.locs) == 1 {
+		var record []string
+		if b.records != nil {
+			record = b.records[0]
+		}
+		rejects.write(file, b.lines[0], err, record)
+		i.log.Warn("Record rejected by the database",
+			slog.Int("batch", b.number),
+			slog.Int64("line", b.lines[0]),
+			slog.Any("error", err),
+		)
+		return 1, nil
+	}
+	mid := len(b.locs) / 2
+	if rejected, err = i.stageBatch(ctx, b.slice(0, mid), file, runID, count, rejects); err != nil {
+		return rejected, err
+	}
+	n, err := i.stageBatch(ctx, b.slice(mid, len(b.locs)), file, runID, count, rejects)
+	return rejected + n, err
+}
+
+// stageColumns of the import_run_rows table, where the records are staged before being applied.
+var stageColumns = []string{"run_id", "seq", "ip_address", "country_code", "country", "city", "latitude", "longitude"}
+
+// writeBatch of geolocations to the staging table. Records are numbered from first, in the order of the data dump.
+// On retries, the records staged by a previous attempt whose result was lost are replaced.
+func (i *Importer) writeBatch(ctx context.Context, locs []Geolocation, runID, first int64, retry bool) error {
+	rows := make([][]any, len(locs))
+	for pos, loc := range locs {
 		// NOTE(henvic): PostgreSQL treats IPv4 and IPv6 versions of the same IP differently.
 		// pgx is normalizing it to IPv6.
-		batch.Queue(importQuery,
-			loc.IPAddress,
-			loc.CountryCode,
-			loc.Country,
-			loc.City,
-			loc.Latitude,
-			loc.Longitude)
-
-		if batch.Len() == i.batchSize {
-			batchNumber++
-			total += batch.Len()
-			results := i.db.SendBatch(ctx, &batch)
-			if err := results.Close(); err != nil {
-				return &stats, fmt.Errorf("batch %d error: %w", batchNumber, err)
-			}
-			i.log.Info("Batch processed",
-				slog.Int("batch", batchNumber),
-				slog.Any("total", total),
-			)
+		rows[pos] = []any{runID, first + int64(pos), loc.IPAddress, loc.CountryCode, loc.Country, loc.City, loc.Latitude, loc.Longitude}
+	}
+	if !retry {
+		_, err := i.db.CopyFrom(ctx, pgx.Identifier{"import_run_rows"}, stageColumns, pgx.CopyFromRows(rows))
+		return err
+	}
+	return pgx.BeginFunc(ctx, i.db, func(tx pgx.Tx) error {
+		const unstageQuery = `DELETE FROM import_run_rows WHERE run_id = $1 AND seq >= $2 AND seq < $3`
+		if _, err := tx.Exec(ctx, unstageQuery, runID, first, first+int64(len(locs))); err != nil {
+			return err
+		}
+		_, err := tx.CopyFrom(ctx, pgx.Identifier{"import_run_rows"}, stageColumns, pgx.CopyFromRows(rows))
+		return err
+	})
+}
+
+// rejectsWriter writes the discarded records to the rejects report as CSV, with their line and the reason.
+// Records rejected by the database are written by the workers, so it is safe for concurrent use.
+// A nil rejectsWriter discards the records.
+type rejectsWriter struct {
+	mu sync.Mutex
+	w  *csv.Writer
+}
+
+func newRejectsWriter(w io.Writer) *rejectsWriter {
+	r := &rejectsWriter{w: csv.NewWriter(w)}
+	r.w.Write([]string{"line", "reason", "record"})
+	return r
+}
 
-			// Recreate a new batch.
-			batch = pgx.Batch{}
+// write a record to the rejects report. The line is prefixed with the name of the file, if any.
+func (r *rejectsWriter) write(file string, line int64, reason error, record []string) {
+	if r == nil {
+		return
+	}
+	pos := strconv.FormatInt(line, 10)
+	if file != "" {
+		pos = file + ":" + pos
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Write(append([]string{pos, reason.Error()}, record...))
+}
+
+func (r *rejectsWriter) flush() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// incomingRows are the records staged by the import run, keeping the last one of each IP address.
+const incomingRows = `SELECT DISTINCT ON (ip_address) ip_address, country_code, country, city, latitude, longitude, run_id
+FROM import_run_rows WHERE run_id = $1 ORDER BY ip_address, seq DESC`
+
+// pruneCandidates matches the geolocations missing from the data dump of the sync import run.
+const pruneCandidates = `g.pruned_by IS NULL
+AND NOT EXISTS (SELECT 1 FROM import_run_rows s WHERE s.run_id = $1 AND s.ip_address = g.ip_address)`
+
+// pruneSampleSize is the number of geolocations to be pruned listed on the log.
+const pruneSampleSize = 10
+
+// apply the records staged by the import run to the geolocations in a single transaction,
+// unless they exceed the thresholds. A sync import also prunes the geolocations missing from the data dump.
+func (i *Importer) apply(ctx context.Context, stats *ImportStats) error {
+	m, err := i.measure(ctx, stats)
+	if err != nil {
+		return err
+	}
+	if err := i.check(m); err != nil {
+		return err
+	}
+	// Retrying is safe, as the staged records are kept until the import run finishes.
+	var retried retryCount
+	err = i.retry(ctx, slog.String("step", "apply"), &retried, func(int) error {
+		return i.applyStaged(ctx, stats)
+	})
+	stats.Retries += int(retried.retries.Load())
+	return err
+}
+
+// applyStaged records of the import run to the geolocations, pruning the missing ones on a sync import.
+func (i *Importer) applyStaged(ctx context.Context, stats *ImportStats) error {
+	return pgx.BeginFunc(ctx, i.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, reimportedQuery, stats.RunID); err != nil {
+			return fmt.Errorf("cannot keep pruned geolocations: %w", err)
+		}
+		if _, err := tx.Exec(ctx, importQuery, stats.RunID); err != nil {
+			return fmt.Errorf("cannot import geolocations: %w", err)
+		}
+		if i.opts.Mode != ImportSync {
+			return nil
 		}
+		const pruneQuery = `UPDATE geolocation g SET pruned_by = $1, updated_at = now() WHERE ` + pruneCandidates
+		ct, err := tx.Exec(ctx, pruneQuery, stats.RunID)
+		if err != nil {
+			return fmt.Errorf("cannot prune geolocations: %w", err)
+		}
+		stats.Pruned = int(ct.RowsAffected())
+		return nil
+	})
+}
 
-		stats.Accepted++
+// measure the records staged by the import run against the current geolocations.
+func (i *Importer) measure(ctx context.Context, stats *ImportStats) (importMetrics, error) {
+	m := importMetrics{
+		accepted:  stats.Accepted,
+		discarded: stats.Discarded,
+	}
+	const countQuery = `WITH incoming AS (` + incomingRows + `)
+SELECT (SELECT count(*) FROM geolocation WHERE pruned_by IS NULL),
+(SELECT count(*) FROM incoming),
+(SELECT count(*) FROM incoming i WHERE NOT EXISTS (SELECT 1 FROM geolocation g WHERE g.ip_address = i.ip_address AND g.pruned_by IS NULL)),
+(SELECT count(*) FROM geolocation g JOIN incoming i USING (ip_address) WHERE g.pruned_by IS NULL
+AND (g.country_code, g.country, g.city, g.latitude, g.longitude) IS DISTINCT FROM (i.country_code, i.country, i.city, i.latitude, i.longitude)),
+(SELECT count(*) FROM geolocation g WHERE ` + pruneCandidates + `)`
+	if err := i.db.QueryRow(ctx, countQuery, stats.RunID).Scan(&m.current, &m.incoming, &m.created, &m.changed, &m.missing); err != nil {
+		return m, fmt.Errorf("cannot measure import: %w", err)
+	}
+	if i.opts.Mode == ImportSync {
+		m.prune = m.missing
 	}
 
-	if batch.Len() > 0 {
-		batchNumber++
-		total += batch.Len()
-		results := i.db.SendBatch(ctx, &batch)
+	if i.opts.Thresholds.MaxCountryDelta > 0 {
+		const countriesQuery = `WITH incoming AS (` + incomingRows + `),
+current AS (SELECT ip_address, country_code FROM geolocation WHERE pruned_by IS NULL)
+SELECT country_code, count(*) FILTER (WHERE before), count(*) FILTER (WHERE NOT before) FROM (
+SELECT country_code, true AS before FROM current
+UNION ALL
+SELECT country_code, false FROM incoming
+UNION ALL
+SELECT c.country_code, false FROM current c WHERE NOT $2 AND NOT EXISTS (SELECT 1 FROM incoming i WHERE i.ip_address = c.ip_address)
+) AS counts GROUP BY country_code`
+		rows, err := i.db.Query(ctx, countriesQuery, stats.RunID, i.opts.Mode == ImportSync)
+		if err != nil {
+			return m, fmt.Errorf("cannot measure import by country: %w", err)
+		}
+		m.countries = map[string]countryCount{}
+		var (
+			code          string
+			before, after int
+		)
+		_, err = pgx.ForEachRow(rows, []any{&code, &before, &after}, func() error {
+			m.countries[code] = countryCount{before: before, after: after}
+			return nil
+		})
+		if err != nil {
+			return m, fmt.Errorf("cannot measure import by country: %w", err)
+		}
+	}
 
-		if err := results.Close(); err != nil {
-			return &stats, fmt.Errorf("batch %d error: %w", batchNumber, err)
+	if i.opts.Mode == ImportSync {
+		rows, err := i.db.Query(ctx, `SELECT g.ip_address FROM geolocation g WHERE `+pruneCandidates+` ORDER BY g.ip_address LIMIT $2`, stats.RunID, pruneSampleSize)
+		var sample []net.IP
+		if err == nil {
+			sample, err = pgx.CollectRows(rows, pgx.RowTo[net.IP])
 		}
-		i.log.Info("Batch processed",
-			slog.Int("batch", batchNumber),
-			slog.Any("total", total),
+		if err != nil {
+			return m, fmt.Errorf("cannot list geolocations to prune: %w", err)
+		}
+		i.log.Info("Prune summary",
+			slog.Int64("run_id", stats.RunID),
+			slog.Int("prune", m.prune),
+			slog.Int("total", m.current),
+			slog.Any("sample", sample),
+			slog.Bool("dry_run", i.opts.DryRun),
 		)
 	}
+	return m, nil
+}
+
+// dryRun stages the data dump on a connection of its own, on a temporary table shadowing the staging table,
+// and measures it against the current geolocations like an import does, without writing anything.
+func (i *Importer) dryRun(ctx context.Context, files []ImportFile, stats *ImportStats) error {
+	// The temporary table is dropped when the connection is closed.
+	conn, err := pgx.ConnectConfig(ctx, i.pool.Config().ConnConfig)
+	if err != nil {
+		return fmt.Errorf("cannot connect to database: %w", err)
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+	const stagingQuery = `CREATE TEMPORARY TABLE import_run_rows (LIKE import_run_rows INCLUDING ALL)`
+	if _, err := conn.Exec(ctx, stagingQuery); err != nil {
+		return fmt.Errorf("cannot create staging table: %w", err)
+	}
+
+	dry := *i
+	dry.db = conn
+	// A connection can't be used concurrently.
+	dry.opts.Workers = 1
+	if err := dry.stream(ctx, files, stats, nil); err != nil {
+		return err
+	}
+	m, err := dry.measure(ctx, stats)
+	if err != nil {
+		return err
+	}
+	stats.Diff = &ImportDiff{
+		New:       m.created,
+		Changed:   m.changed,
+		Unchanged: m.incoming - m.created - m.changed,
+		Removed:   m.missing,
+	}
+	stats.Pruned = m.prune
+	return i.check(m)
+}
+
+// Fields of a geolocation record.
+const (
+	fieldIPAddress = iota
+	fieldLatitude
+	fieldLongitude
+	fieldCountryCode
+	fieldCountry
+	fieldCity
+)
 
-	return &stats, nil
+// recordFields names the fields of a geolocation record.
+var recordFields = [...]string{
+	fieldIPAddress:   "ip_address",
+	fieldLatitude:    "latitude",
+	fieldLongitude:   "longitude",
+	fieldCountryCode: "country_code",
+	fieldCountry:     "country",
+	fieldCity:        "city",
 }
 
-// loadRecord into the Geolocation struct.
-func (i *Importer) loadRecord(record []string, loc *Geolocation) error {
-	// Try to find the IP field.
+// recordMapping has the column (starting at 1) where each field of a record was found, or 0 if missing.
+type recordMapping [len(recordFields)]int
+
+// loadRecord into the Geolocation struct, returning the columns where its fields were found.
+func (i *Importer) loadRecord(record []string, loc *Geolocation) (recordMapping, error) {
+	var mapping recordMapping
+
+	// Keep the record intact for the rejects report, and track the columns of the remaining values.
+	record = slices.Clone(record)
+	cols := make([]int, len(record))
+	for pos := range cols {
+		cols[pos] = pos + 1
+	}
+	take := func(field, pos int) {
+		mapping[field] = cols[pos]
+		record = slices.Delete(record, pos, pos+1)
+		cols = slices.Delete(cols, pos, pos+1)
+	}
+
+	// Try to find the IP field. The last one wins.
+	ipPos := -1
 	for pos, v := range record {
 		if ip := net.ParseIP(v); ip != nil {
-			loc.IPAddress = ip
-			record = append(record[:pos], record[pos+1:]...)
+			loc.IPAddress, ipPos = ip, pos
 		}
 	}
-	if len(loc.IPAddress) == 0 {
-		return errors.New("no valid IP address found")
+	if ipPos == -1 {
+		return mapping, errors.New("no valid IP address found")
 	}
+	take(fieldIPAddress, ipPos)
 
 	// Try to find the latitude and longitude fields.
 	if len(record) >= 2 {
 		for pos := 0; pos < len(record)-1; pos++ {
-			lat, err := strconv.ParseFloat(record[pos], 64)
-			if err != nil || record[pos] == "0" || lat < -90 || lat > 90 {
+			if record[pos] == "0" || !isCoordinate(record[pos], 90) {
 				continue
 			}
-			lon, err := strconv.ParseFloat(record[pos+1], 64)
-			if err != nil || record[pos+1] == "0" || lon < -180 || lon > 180 {
+			if record[pos+1] == "0" || !isCoordinate(record[pos+1], 180) {
 				continue
 			}
 			// Maintain exact precision for coordinates.
 			loc.Latitude = json.Number(record[pos])
 			loc.Longitude = json.Number(record[pos+1])
-			record = append(record[:pos], record[pos+2:]...)
+			take(fieldLatitude, pos)
+			take(fieldLongitude, pos)
 			break
 		}
 	}
 
 	// Try to find the country code field.
 	for pos, v := range record {
-		if isCountryCode(record[pos]) {
+		if isCountryCode(v) {
 			loc.CountryCode = v
-			record = append(record[:pos], record[pos+1:]...)
+			take(fieldCountryCode, pos)
 			break
 		}
 	}
@@ -179,29 +886,14 @@ func (i *Importer) loadRecord(record []string, loc *Geolocation) error {
 	// Try to find country and city.
 	if len(record) >= 1 {
 		loc.Country = record[0]
+		mapping[fieldCountry] = cols[0]
 	}
 	if len(record) >= 2 {
 		loc.City = record[1]
+		mapping[fieldCity] = cols[1]
 	}
 
 	// If no useful values are found, assume that the data is corrupted.
-	if loc.City == "" && loc.Country == "" && loc.CountryCode == "" &&
-		loc.Latitude == "" && loc.Longitude == "" {
-		return errors.New("no useful data found")
-	}
 	// Otherwise, accept the record.
-	return nil
-}
-
-// isCountryCode naively checks if the given string is an uppercase 2-letter ISO 3166-1 country code.
-func isCountryCode(code string) bool {
-	if len(code) != 2 {
-		return false
-	}
-	for _, c := range code {
-		if !unicode.IsUpper(c) {
-			return false
-		}
-	}
-	return true
+	return mapping, loc.Validate()
 }
@@ -1,15 +1,29 @@
 package vio_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/henvic/pgtools/sqltest"
 	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/decompress"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/klauspost/compress/zstd"
 )
 
 func TestImporter(t *testing.T) {
@@ -26,13 +40,23 @@ func TestImporter(t *testing.T) {
 	}
 	defer f.Close()
 
-	stats, err := vio.NewImporter(3, slog.Default(), pool).Stream(context.Background(), f)
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(context.Background(), f, vio.ImportSource{Name: "example.csv", Operator: "test"})
 	if stats == nil {
 		t.Error("stats should not be nil")
 	}
 	wantStats := &vio.ImportStats{
+		RunID:     1,
 		Accepted:  7,
 		Discarded: 4,
+		Mapping: map[string]map[int]int{
+			"ip_address":   {1: 7},
+			"country_code": {2: 7},
+			"country":      {3: 7},
+			"city":         {4: 7},
+			"latitude":     {5: 6},
+			"longitude":    {6: 6},
+		},
+		Files: []vio.ImportFileStats{{Name: "example.csv", Accepted: 7, Discarded: 4}},
 	}
 	if diff := cmp.Diff(wantStats, stats, cmpopts.IgnoreFields(vio.ImportStats{}, "TimeElapsed")); diff != "" {
 		t.Errorf("stats mismatch: %v", diff)
@@ -41,3 +65,688 @@ func TestImporter(t *testing.T) {
 		t.Errorf("cannot import location data: %v", err)
 	}
 }
+
+func TestImporterDryRun(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+
+	f, err := os.Open("testdata/example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(ctx, f, vio.ImportSource{Name: "example.csv"}); err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+
+	const dump = `200.106.141.15,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346
+218.119.112.54,VA,Iceland,Reykjavik,23.554821245315708,-24.978121154412634,7309672297
+192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0
+,NL,Netherlands,Amsterdam,52.37,4.89,0
+`
+	var rejects strings.Builder
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+		BatchSize: 2,
+		DryRun:    true,
+		Rejects:   &rejects,
+	}).Stream(ctx, strings.NewReader(dump), vio.ImportSource{Name: "dump.csv"})
+	if err != nil {
+		t.Fatalf("dry run error: %v", err)
+	}
+	wantDiff := &vio.ImportDiff{New: 1, Changed: 1, Unchanged: 1, Removed: 5}
+	if diff := cmp.Diff(wantDiff, stats.Diff); diff != "" {
+		t.Errorf("diff mismatch: %v", diff)
+	}
+	wantRejects := "line,reason,record\n4,no valid IP address found,,NL,Netherlands,Amsterdam,52.37,4.89,0\n"
+	if rejects.String() != wantRejects {
+		t.Errorf("rejects = %q, want %q", rejects.String(), wantRejects)
+	}
+
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+	if runs, err := service.ListImportRuns(ctx); err != nil || len(runs) != 1 {
+		t.Errorf("dry run should not create an import run: %v, %v", runs, err)
+	}
+	if loc, err := service.LookupLocation(ctx, "218.119.112.54"); err != nil || loc.City != "Gavinshire" {
+		t.Errorf("dry run should not change geolocations: %+v, %v", loc, err)
+	}
+
+	// Only the last record of an IP address is imported, so it is the only one compared.
+	const repeated = `218.119.112.54,NL,Netherlands,Amsterdam,52.37,4.89,0
+192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0
+218.119.112.54,VA,Iceland,Gavinshire,23.554821245315708,-24.978121154412634,7309672297
+192.0.2.1,DE,Germany,Berlin,52.52,13.40,0
+192.0.2.1,DE,Germany,Berlin,52.52,13.40,0
+`
+	stats, err = vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+		BatchSize: 1,
+		Workers:   4,
+		DryRun:    true,
+	}).Stream(ctx, strings.NewReader(repeated), vio.ImportSource{Name: "dump.csv"})
+	if err != nil {
+		t.Fatalf("dry run error: %v", err)
+	}
+	wantDiff = &vio.ImportDiff{New: 1, Unchanged: 1, Removed: 6}
+	if diff := cmp.Diff(wantDiff, stats.Diff); diff != "" {
+		t.Errorf("diff of repeated IP addresses mismatch: %v", diff)
+	}
+}
+
+func TestImporterSync(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+
+	f, err := os.Open("testdata/example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(ctx, f, vio.ImportSource{Name: "example.csv"}); err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+
+	// 31.185.249.104 and 156.224.222.114 are missing: 2 of 7 geolocations.
+	const dump = `200.106.141.15,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346
+218.119.112.54,VA,Iceland,Gavinshire,23.554821245315708,-24.978121154412634,7309672297
+160.103.7.140,CZ,Nicaragua,New Neva,-68.31023296602508,-37.62435199624531,7301823115
+70.95.73.73,TL,Saudi Arabia,Gradymouth,-49.16675918861615,-86.05920084416894,2559997162
+125.159.20.54,LI,Guyana,Port Karson,-78.2274228596799,-163.26218895343357,1337885276
+`
+	sync := func(opts vio.ImportOptions) (*vio.ImportStats, error) {
+		opts.BatchSize = 3
+		opts.Mode = vio.ImportSync
+		return vio.NewImporter(slog.Default(), pool, opts).Stream(ctx, strings.NewReader(dump), vio.ImportSource{Name: "sync.csv"})
+	}
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+	exists := func(ip string) bool {
+		t.Helper()
+		loc, err := service.LookupLocation(ctx, ip)
+		if err != nil {
+			t.Fatalf("cannot lookup location: %v", err)
+		}
+		return loc != nil
+	}
+
+	if _, err := sync(vio.ImportOptions{MaxPruneRatio: 0.1}); !errors.Is(err, vio.ErrPruneLimit) {
+		t.Errorf("sync import above limit error = %v, want %v", err, vio.ErrPruneLimit)
+	}
+	if _, err := sync(vio.ImportOptions{DryRun: true}); err != nil {
+		t.Errorf("sync import dry run without limit error = %v", err)
+	}
+	if stats, err := sync(vio.ImportOptions{MaxPruneRatio: 0.5, DryRun: true}); err != nil || stats.Pruned != 2 || stats.Diff.Removed != 2 {
+		t.Errorf("sync import dry run = %+v, %v, want 2 pruned", stats, err)
+	}
+	if !exists("31.185.249.104") {
+		t.Error("geolocation should not be pruned by dry run")
+	}
+	// Nor are the records of the data dump imported.
+	if _, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{Mode: vio.ImportSync, MaxPruneRatio: 1, DryRun: true}).Stream(ctx,
+		strings.NewReader("200.106.141.15,NL,Netherlands,Amsterdam,52.37,4.89,0\n"), vio.ImportSource{Name: "sync.csv"}); err != nil {
+		t.Fatalf("cannot sync import on dry run: %v", err)
+	}
+	if loc, err := service.LookupLocation(ctx, "200.106.141.15"); err != nil || loc == nil || loc.City != "DuBuquemouth" {
+		t.Errorf("geolocation should not be changed by dry run: %+v, %v", loc, err)
+	}
+
+	stats, err := sync(vio.ImportOptions{MaxPruneRatio: 0.5})
+	if err != nil || stats.Pruned != 2 {
+		t.Fatalf("sync import = %+v, %v, want 2 pruned", stats, err)
+	}
+	if exists("31.185.249.104") || !exists("200.106.141.15") {
+		t.Error("only geolocations missing from the data dump should be pruned")
+	}
+
+	rollback, err := service.RollbackImportRun(ctx, stats.RunID)
+	if err != nil {
+		t.Fatalf("cannot roll back import run: %v", err)
+	}
+	if rollback.Unpruned != 2 {
+		t.Errorf("rollback unpruned %d geolocations, want 2", rollback.Unpruned)
+	}
+	if !exists("31.185.249.104") {
+		t.Error("pruned geolocation should be restored by rollback")
+	}
+}
+
+func TestImporterReimportRollback(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	example, err := os.ReadFile("testdata/example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := func(dump string, opts vio.ImportOptions) *vio.ImportStats {
+		t.Helper()
+		opts.BatchSize = 3
+		stats, err := vio.NewImporter(slog.Default(), pool, opts).Stream(ctx, strings.NewReader(dump), vio.ImportSource{Name: "dump.csv"})
+		if err != nil {
+			t.Fatalf("cannot import location data: %v", err)
+		}
+		return stats
+	}
+	lookup := func(ip string) *vio.Geolocation {
+		t.Helper()
+		loc, err := service.LookupLocation(ctx, ip)
+		if err != nil {
+			t.Fatalf("cannot lookup location: %v", err)
+		}
+		return loc
+	}
+	stream(string(example), vio.ImportOptions{})
+
+	// 31.185.249.104 and 156.224.222.114 are pruned, and then 31.185.249.104 is imported again.
+	pruned := stream(`200.106.141.15,SI,Nepal,DuBuquemouth,-84.87503094689836,7.206435933364332,7823011346
+218.119.112.54,VA,Iceland,Gavinshire,23.554821245315708,-24.978121154412634,7309672297
+160.103.7.140,CZ,Nicaragua,New Neva,-68.31023296602508,-37.62435199624531,7301823115
+70.95.73.73,TL,Saudi Arabia,Gradymouth,-49.16675918861615,-86.05920084416894,2559997162
+125.159.20.54,LI,Guyana,Port Karson,-78.2274228596799,-163.26218895343357,1337885276
+`, vio.ImportOptions{Mode: vio.ImportSync, MaxPruneRatio: 0.5})
+	if pruned.Pruned != 2 {
+		t.Fatalf("sync import pruned %d geolocations, want 2", pruned.Pruned)
+	}
+	reimported := stream("31.185.249.104,NL,Netherlands,Amsterdam,52.37,4.89,0\n", vio.ImportOptions{})
+	if loc := lookup("31.185.249.104"); loc == nil || loc.City != "Amsterdam" {
+		t.Fatalf("pruned geolocation should be imported again: %+v", loc)
+	}
+
+	rollback, err := service.RollbackImportRun(ctx, reimported.RunID)
+	if err != nil {
+		t.Fatalf("cannot roll back import run: %v", err)
+	}
+	if want := (vio.ImportRollback{Restored: 1}); *rollback != want {
+		t.Errorf("rollback = %+v, want %+v", rollback, want)
+	}
+	if loc := lookup("31.185.249.104"); loc != nil {
+		t.Errorf("geolocation should be pruned again by rollback: %+v", loc)
+	}
+
+	if rollback, err = service.RollbackImportRun(ctx, pruned.RunID); err != nil {
+		t.Fatalf("cannot roll back sync import run: %v", err)
+	}
+	if rollback.Unpruned != 2 {
+		t.Errorf("rollback unpruned %d geolocations, want 2", rollback.Unpruned)
+	}
+	if loc := lookup("31.185.249.104"); loc == nil || loc.City != "New Rodrick" {
+		t.Errorf("geolocation should be restored to the version before it was pruned: %+v", loc)
+	}
+}
+
+func TestImporterThresholds(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	example, err := os.ReadFile("testdata/example.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := func(dump string, opts vio.ImportOptions) error {
+		opts.BatchSize = 3
+		_, err := vio.NewImporter(slog.Default(), pool, opts).Stream(ctx, strings.NewReader(dump), vio.ImportSource{Name: "dump.csv"})
+		return err
+	}
+
+	// 4 of 11 records of the example are discarded.
+	if err := stream(string(example), vio.ImportOptions{Thresholds: vio.ImportThresholds{MaxDiscardRatio: 0.2}}); !errors.Is(err, vio.ErrImportThreshold) {
+		t.Errorf("import above discard ratio error = %v, want %v", err, vio.ErrImportThreshold)
+	}
+	if loc, err := service.LookupLocation(ctx, "200.106.141.15"); err != nil || loc != nil {
+		t.Errorf("import above threshold should not change geolocations: %+v, %v", loc, err)
+	}
+	if err := stream(string(example), vio.ImportOptions{}); err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+
+	// Every geolocation moves to another country.
+	const dump = `200.106.141.15,NL,Netherlands,Amsterdam,52.37,4.89,0
+218.119.112.54,NL,Netherlands,Amsterdam,52.37,4.89,0
+160.103.7.140,NL,Netherlands,Amsterdam,52.37,4.89,0
+70.95.73.73,NL,Netherlands,Amsterdam,52.37,4.89,0
+125.159.20.54,NL,Netherlands,Amsterdam,52.37,4.89,0
+31.185.249.104,NL,Netherlands,Amsterdam,52.37,4.89,0
+156.224.222.114,NL,Netherlands,Amsterdam,52.37,4.89,0
+`
+	tests := []struct {
+		name string
+		opts vio.ImportOptions
+	}{
+		{"min_records", vio.ImportOptions{Thresholds: vio.ImportThresholds{MinRecords: 10}}},
+		{"max_change_ratio", vio.ImportOptions{Thresholds: vio.ImportThresholds{MaxChangeRatio: 0.5}}},
+		{"max_country_delta", vio.ImportOptions{Thresholds: vio.ImportThresholds{MaxCountryDelta: 0.5}}},
+		{"dry_run", vio.ImportOptions{DryRun: true, Thresholds: vio.ImportThresholds{MaxCountryDelta: 0.5}}},
+	}
+	for _, tt := range tests {
+		if err := stream(dump, tt.opts); !errors.Is(err, vio.ErrImportThreshold) {
+			t.Errorf("%s: import error = %v, want %v", tt.name, err, vio.ErrImportThreshold)
+		}
+	}
+	if loc, err := service.LookupLocation(ctx, "200.106.141.15"); err != nil || loc.CountryCode != "SI" {
+		t.Errorf("import above threshold should not change geolocations: %+v, %v", loc, err)
+	}
+	// Aborted import runs can't be resumed, so their staged records are discarded.
+	var staged, checkpoints int
+	if err := pool.QueryRow(ctx, `SELECT (SELECT count(*) FROM import_run_rows), (SELECT count(*) FROM import_run_checkpoints)`).Scan(
+		&staged, &checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	if staged != 0 || checkpoints != 0 {
+		t.Errorf("aborted import runs kept %d staged records and %d checkpoints", staged, checkpoints)
+	}
+	if err := stream(dump, vio.ImportOptions{Thresholds: vio.ImportThresholds{MaxChangeRatio: 1}}); err != nil {
+		t.Errorf("import within thresholds error = %v", err)
+	}
+}
+
+func TestImporterWorkers(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+
+	var dump strings.Builder
+	for n := range 50 {
+		fmt.Fprintf(&dump, "192.0.2.%d,NL,Netherlands,Amsterdam,52.37,4.89,0\n", n)
+	}
+	// The last record of an IP address wins, even if batches are written out of order.
+	dump.WriteString("192.0.2.1,NL,Netherlands,Rotterdam,51.92,4.48,0\n")
+
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+		BatchSize: 2,
+		Workers:   4,
+	}).Stream(ctx, strings.NewReader(dump.String()), vio.ImportSource{Name: "dump.csv"})
+	if err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+	if stats.Accepted != 51 {
+		t.Errorf("accepted %d records, want 51", stats.Accepted)
+	}
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+	if loc, err := service.LookupLocation(ctx, "192.0.2.1"); err != nil || loc.City != "Rotterdam" {
+		t.Errorf("last record should win: %+v, %v", loc, err)
+	}
+}
+
+func TestImporterResume(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	var dump strings.Builder
+	for n := range 10 {
+		fmt.Fprintf(&dump, "192.0.2.%d,NL,Netherlands,Amsterdam,52.37,4.89,0\n", n)
+	}
+	dump.WriteString("invalid\n")
+	for n := 10; n < 20; n++ {
+		fmt.Fprintf(&dump, "192.0.2.%d,NL,Netherlands,Amsterdam,52.37,4.89,0\n", n)
+	}
+	data := dump.String()
+	interrupted := data[:strings.Index(data, "192.0.2.15,")]
+
+	stream := func(r io.Reader, resume bool) (*vio.ImportStats, error) {
+		return vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+			BatchSize: 2,
+			Workers:   2,
+			Resume:    resume,
+		}).Stream(ctx, r, vio.ImportSource{Name: "dump.csv"})
+	}
+
+	if _, err := stream(strings.NewReader(data), true); !errors.Is(err, vio.ErrNoCheckpoint) {
+		t.Errorf("resume without import run error = %v, want %v", err, vio.ErrNoCheckpoint)
+	}
+
+	errLost := errors.New("connection lost")
+	if _, err := stream(io.MultiReader(strings.NewReader(interrupted), iotest.ErrReader(errLost)), false); !errors.Is(err, errLost) {
+		t.Fatalf("interrupted import error = %v, want %v", err, errLost)
+	}
+	if loc, err := service.LookupLocation(ctx, "192.0.2.0"); err != nil || loc != nil {
+		t.Errorf("interrupted import should not change geolocations: %+v, %v", loc, err)
+	}
+
+	if _, err := stream(strings.NewReader(strings.Replace(data, "Amsterdam", "Rotterdam", 1)), true); !errors.Is(err, vio.ErrCheckpointMismatch) {
+		t.Errorf("resume with other input error = %v, want %v", err, vio.ErrCheckpointMismatch)
+	}
+
+	stats, err := stream(strings.NewReader(data), true)
+	if err != nil {
+		t.Fatalf("cannot resume import: %v", err)
+	}
+	if stats.RunID != 1 || stats.Accepted != 20 || stats.Discarded != 1 {
+		t.Errorf("resumed import stats = %+v, want run 1 with 20 records accepted and 1 discarded", stats)
+	}
+	for _, ip := range []string{"192.0.2.0", "192.0.2.14", "192.0.2.19"} {
+		if loc, err := service.LookupLocation(ctx, ip); err != nil || loc == nil {
+			t.Errorf("LookupLocation(%q) = %+v, %v", ip, loc, err)
+		}
+	}
+
+	runs, err := service.ListImportRuns(ctx)
+	if err != nil {
+		t.Fatalf("cannot list import runs: %v", err)
+	}
+	sum := sha256.Sum256([]byte(data))
+	if len(runs) != 1 || runs[0].Status != vio.ImportRunSucceeded || runs[0].Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("import runs = %+v, want resumed import run with the checksum of the whole data dump", runs)
+	}
+
+	if _, err := stream(strings.NewReader(data), true); !errors.Is(err, vio.ErrNoCheckpoint) {
+		t.Errorf("resume succeeded import run error = %v, want %v", err, vio.ErrNoCheckpoint)
+	}
+}
+
+func TestImporterRetry(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+
+	// Staging fails with a serialization failure while the sequence is at most failures.
+	// Sequences aren't rolled back with the failed statement.
+	if _, err := pool.Exec(ctx, `CREATE SEQUENCE staging_attempts;
+CREATE TABLE staging_failures (n integer NOT NULL);
+INSERT INTO staging_failures VALUES (0);
+CREATE FUNCTION fail_staging() RETURNS trigger AS $$
+BEGIN
+	IF nextval('staging_attempts') <= (SELECT n FROM staging_failures) THEN
+		RAISE EXCEPTION 'could not serialize access' USING ERRCODE = 'serialization_failure';
+	END IF;
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER fail_staging AFTER INSERT ON import_run_rows FOR EACH STATEMENT EXECUTE FUNCTION fail_staging();`); err != nil {
+		t.Fatalf("cannot create failing trigger: %v", err)
+	}
+	stream := func(failures, attempts int) (*vio.ImportStats, error) {
+		if _, err := pool.Exec(ctx, `SELECT setval('staging_attempts', 1, false)`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pool.Exec(ctx, `UPDATE staging_failures SET n = $1`, failures); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open("testdata/example.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		return vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+			BatchSize: 3,
+			Retry: vio.ImportRetry{
+				MaxAttempts: attempts,
+				Backoff:     time.Millisecond,
+			},
+		}).Stream(ctx, file, vio.ImportSource{Name: "example.csv"})
+	}
+
+	_, err := stream(2, 2)
+	if pgErr := (*pgconn.PgError)(nil); !errors.As(err, &pgErr) || pgErr.Code != "40001" {
+		t.Errorf("import after too many attempts error = %v, want serialization failure", err)
+	}
+
+	stats, err := stream(2, 3)
+	if err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+	if stats.Retries != 2 || stats.RetriedBatches != 1 || stats.Accepted != 7 {
+		t.Errorf("import stats = %+v, want 2 retries of 1 batch and 7 records accepted", stats)
+	}
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+	if loc, err := service.LookupLocation(ctx, "200.106.141.15"); err != nil || loc == nil {
+		t.Errorf("LookupLocation() = %+v, %v", loc, err)
+	}
+
+	// Applying the staged records is retried too.
+	if _, err := pool.Exec(ctx, `DROP TRIGGER fail_staging ON import_run_rows;
+CREATE TRIGGER fail_staging AFTER INSERT OR UPDATE ON geolocation FOR EACH STATEMENT EXECUTE FUNCTION fail_staging();`); err != nil {
+		t.Fatalf("cannot create failing trigger: %v", err)
+	}
+	stats, err = stream(1, 2)
+	if err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+	if stats.Retries != 1 || stats.RetriedBatches != 0 {
+		t.Errorf("import stats = %+v, want 1 retry and no batches retried", stats)
+	}
+}
+
+func TestImporterResumeCompressed(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	var dump strings.Builder
+	for n := range 20 {
+		fmt.Fprintf(&dump, "192.0.2.%d,NL,Netherlands,Amsterdam,52.37,4.89,0\n", n)
+	}
+	data := dump.String()
+	sum := sha256.Sum256([]byte(data))
+
+	var gz, zs bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	zw, err := zstd.NewWriter(&zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []io.WriteCloser{gw, zw} {
+		if _, err := io.WriteString(w, data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The checkpoint is on the decompressed input, which is interrupted.
+	errLost := errors.New("connection lost")
+	interrupt := int64(strings.Index(data, "192.0.2.15,"))
+	for name, compressed := range map[string][]byte{
+		"dump.csv.gz":  gz.Bytes(),
+		"dump.csv.zst": zs.Bytes(),
+	} {
+		file := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(file, compressed, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		stream := func(interrupted, resume bool) (*vio.ImportStats, error) {
+			return vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+				BatchSize: 2,
+				Resume:    resume,
+			}).StreamFiles(ctx, []vio.ImportFile{{
+				Name: name,
+				Open: func() (io.ReadCloser, error) {
+					r, err := decompress.Open(file)
+					if err != nil || !interrupted {
+						return r, err
+					}
+					return struct {
+						io.Reader
+						io.Closer
+					}{io.MultiReader(io.LimitReader(r, interrupt), iotest.ErrReader(errLost)), r}, nil
+				},
+			}}, vio.ImportSource{Name: name})
+		}
+		if _, err := stream(true, false); !errors.Is(err, errLost) {
+			t.Fatalf("%s: interrupted import error = %v, want %v", name, err, errLost)
+		}
+		stats, err := stream(false, true)
+		if err != nil {
+			t.Fatalf("%s: cannot resume import: %v", name, err)
+		}
+		if stats.Accepted != 20 || stats.Discarded != 0 {
+			t.Errorf("%s: resumed import stats = %+v, want 20 records accepted", name, stats)
+		}
+		runs, err := service.ListImportRuns(ctx)
+		if err != nil {
+			t.Fatalf("cannot list import runs: %v", err)
+		}
+		for _, run := range runs {
+			if run.ID == stats.RunID && run.Checksum != hex.EncodeToString(sum[:]) {
+				t.Errorf("%s: import run checksum = %s, want the checksum of the decompressed data", name, run.Checksum)
+			}
+		}
+	}
+	if loc, err := service.LookupLocation(ctx, "192.0.2.19"); err != nil || loc == nil {
+		t.Errorf("LookupLocation(192.0.2.19) = %+v, %v", loc, err)
+	}
+}
+
+func TestImporterRejectedRecords(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+
+	// PostgreSQL rejects text with NUL characters or invalid UTF-8.
+	const dump = "192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0\n" +
+		"192.0.2.2,NL,Netherlands,Amster\x00dam,52.37,4.89,0\n" +
+		"192.0.2.3,NL,Netherlands,Amsterdam,52.37,4.89,0\n" +
+		"192.0.2.4,NL,Netherlands,Rotter\xffdam,51.92,4.48,0\n" +
+		"192.0.2.5,NL,Netherlands,Amsterdam,52.37,4.89,0\n"
+
+	var rejects strings.Builder
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+		BatchSize: 5,
+		Rejects:   &rejects,
+	}).Stream(ctx, strings.NewReader(dump), vio.ImportSource{Name: "dump.csv"})
+	if err != nil {
+		t.Fatalf("cannot import location data: %v", err)
+	}
+	if stats.Accepted != 3 || stats.Discarded != 2 {
+		t.Errorf("import stats = %+v, want 3 records accepted and 2 discarded", stats)
+	}
+
+	lines := strings.Split(strings.TrimSpace(rejects.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "2,") || !strings.HasPrefix(lines[2], "4,") {
+		t.Errorf("rejects = %q, want records on lines 2 and 4", rejects.String())
+	}
+
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+	for ip, want := range map[string]bool{
+		"192.0.2.1": true,
+		"192.0.2.2": false,
+		"192.0.2.3": true,
+		"192.0.2.4": false,
+		"192.0.2.5": true,
+	} {
+		if loc, err := service.LookupLocation(ctx, ip); err != nil || (loc != nil) != want {
+			t.Errorf("LookupLocation(%q) = %+v, %v, want found = %v", ip, loc, err, want)
+		}
+	}
+}
+
+func TestImporterFiles(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	service := vio.NewService(vio.NewPostgres(pool, slog.Default()))
+
+	shards := []string{
+		"192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0\n192.0.2.2,NL,Netherlands,Amsterdam,52.37,4.89,0\n",
+		"192.0.2.3,NL,Netherlands,Amsterdam,52.37,4.89,0\ninvalid\n192.0.2.4,NL,Netherlands,Amsterdam,52.37,4.89,0\n",
+		// No line break at the end.
+		"192.0.2.5,NL,Netherlands,Amsterdam,52.37,4.89,0\n192.0.2.1,NL,Netherlands,Rotterdam,51.92,4.48,0",
+	}
+	errLost := errors.New("connection lost")
+	files := func(interrupt bool) []vio.ImportFile {
+		var files []vio.ImportFile
+		for n, shard := range shards {
+			files = append(files, vio.ImportFile{
+				Name: fmt.Sprintf("dump-%d.csv", n+1),
+				Open: func() (io.ReadCloser, error) {
+					if interrupt && n == 2 {
+						return io.NopCloser(io.MultiReader(strings.NewReader(shard[:strings.Index(shard, "192.0.2.1,")]),
+							iotest.ErrReader(errLost))), nil
+					}
+					return io.NopCloser(strings.NewReader(shard)), nil
+				},
+			})
+		}
+		return files
+	}
+	stream := func(files []vio.ImportFile, resume bool, rejects io.Writer) (*vio.ImportStats, error) {
+		return vio.NewImporter(slog.Default(), pool, vio.ImportOptions{
+			BatchSize: 2,
+			Rejects:   rejects,
+			Resume:    resume,
+		}).StreamFiles(ctx, files, vio.ImportSource{Name: "dump-*.csv"})
+	}
+
+	var rejects strings.Builder
+	if _, err := stream(files(true), false, &rejects); !errors.Is(err, errLost) {
+		t.Fatalf("interrupted import error = %v, want %v", err, errLost)
+	}
+	if want := "line,reason,record\ndump-2.csv:2,"; !strings.HasPrefix(rejects.String(), want) {
+		t.Errorf("rejects = %q, want prefix %q", rejects.String(), want)
+	}
+
+	stats, err := stream(files(false), true, nil)
+	if err != nil {
+		t.Fatalf("cannot resume import: %v", err)
+	}
+	if stats.RunID != 1 || stats.Accepted != 6 || stats.Discarded != 1 {
+		t.Errorf("import stats = %+v, want run 1 with 6 records accepted and 1 discarded", stats)
+	}
+	// The records of the files up to the checkpoint were staged before resuming.
+	wantFiles := []vio.ImportFileStats{
+		{Name: "dump-1.csv"},
+		{Name: "dump-2.csv"},
+		{Name: "dump-3.csv", Accepted: 2},
+	}
+	if diff := cmp.Diff(wantFiles, stats.Files); diff != "" {
+		t.Errorf("file stats mismatch: %v", diff)
+	}
+	if loc, err := service.LookupLocation(ctx, "192.0.2.1"); err != nil || loc.City != "Rotterdam" {
+		t.Errorf("last record should win across files: %+v, %v", loc, err)
+	}
+
+	runs, err := service.ListImportRuns(ctx)
+	if err != nil {
+		t.Fatalf("cannot list import runs: %v", err)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(shards, "")))
+	if len(runs) != 1 || runs[0].Checksum != hex.EncodeToString(sum[:]) {
+		t.Errorf("import runs = %+v, want checksum of the files", runs)
+	}
+}
@@ -0,0 +1,78 @@
+package vio
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Statuses of an import run.
+const (
+	ImportRunRunning    = "running"
+	ImportRunSucceeded  = "succeeded"
+	ImportRunFailed     = "failed"
+	ImportRunRolledBack = "rolled_back"
+)
+
+// ImportRun of a vendor data dump.
+type ImportRun struct {
+	ID int64
+
+	// Source is the name of the data dump, e.g., its file name.
+	Source string
+
+	// Mode of the import: ImportUpsert or ImportSync.
+	Mode string
+
+	// Checksum is the SHA-256 of the data dump. Empty unless it was fully read.
+	Checksum string
+
+	// Operator who ran the import, e.g., cli:<user>.
+	Operator string
+
+	Status    string
+	Accepted  int64
+	Discarded int64
+	Pruned    int64
+
+	// Error that stopped the import, if any.
+	Error string
+
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
+
+// ImportRollback is the result of rolling back an import run.
+type ImportRollback struct {
+	// Restored is the number of geolocations restored to the version before the import run,
+	// including the ones pruned before it imported them again.
+	Restored int64
+
+	// Deleted is the number of geolocations created by the import run.
+	Deleted int64
+
+	// Unpruned is the number of geolocations pruned by the import run that were restored.
+	Unpruned int64
+}
+
+var (
+	// ErrImportRunNotFound is returned when the import run doesn't exist.
+	ErrImportRunNotFound = errors.New("import run not found")
+
+	// ErrImportRunNotFinished is returned when trying to roll back an import run that is still running.
+	ErrImportRunNotFinished = errors.New("import run is still running")
+
+	// ErrImportRunRolledBack is returned when trying to roll back an import run twice.
+	ErrImportRunRolledBack = errors.New("import run was already rolled back")
+)
+
+// ListImportRuns returns all import runs, most recent first.
+func (s *Service) ListImportRuns(ctx context.Context) ([]ImportRun, error) {
+	return s.db.ListImportRuns(ctx)
+}
+
+// RollbackImportRun reverts the geolocations last changed by the import run to their previous versions,
+// deleting the ones it created and restoring the ones it pruned. Geolocations changed afterwards are kept.
+func (s *Service) RollbackImportRun(ctx context.Context, id int64) (*ImportRollback, error) {
+	return s.db.RollbackImportRun(ctx, id)
+}
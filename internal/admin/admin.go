@@ -0,0 +1,181 @@
+// Package admin implements the HTTP server for diagnosing and operating the service.
+// It must not be exposed on the same address as the public API.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/henvic/vio/internal/api"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NewServer creates a new admin server.
+// config is called to get the effective configuration of the service, which must have secrets redacted.
+func NewServer(address string, pool *pgxpool.Pool, config func() any, log *slog.Logger) *Server {
+	return &Server{
+		address: address,
+		pool:    pool,
+		config:  config,
+		log:     log,
+		started: time.Now(),
+	}
+}
+
+// Server for administration.
+type Server struct {
+	address string
+	pool    *pgxpool.Pool
+	config  func() any
+	log     *slog.Logger
+	started time.Time
+	http    *http.Server
+}
+
+// Run starts the admin HTTP server.
+func (s *Server) Run(ctx context.Context) error {
+	s.http = &http.Server{
+		Handler: s.handler(),
+
+		ReadHeaderTimeout: 5 * time.Second, // mitigate risk of Slowloris Attack
+	}
+	l, err := api.Listen(s.address)
+	if err != nil {
+		return err
+	}
+	s.log.Info("admin HTTP server listening", slog.Any("address", l.Addr()))
+	if err := s.http.Serve(l); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown admin HTTP server.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.log.Info("shutting down admin HTTP server gracefully")
+	if s.http != nil {
+		if err := s.http.Shutdown(ctx); err != nil {
+			s.log.Error("graceful shutdown of admin HTTP server failed", slog.Any("error", err))
+		}
+	}
+}
+
+// handler for the admin routes.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /version", s.versionHandler)
+	mux.HandleFunc("GET /runtime", s.runtimeHandler)
+	mux.HandleFunc("GET /config", s.configHandler)
+	mux.HandleFunc("GET /pool", s.poolHandler)
+	return mux
+}
+
+// Version of the build.
+type Version struct {
+	GoVersion string            `json:"go_version"`
+	Path      string            `json:"path"`
+	Version   string            `json:"version"`
+	Settings  map[string]string `json:"settings"`
+}
+
+func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	v := Version{
+		GoVersion: runtime.Version(),
+		Settings:  map[string]string{},
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		v.Path = bi.Main.Path
+		v.Version = bi.Main.Version
+		for _, setting := range bi.Settings {
+			v.Settings[setting.Key] = setting.Value
+		}
+	}
+	writeJSON(w, v)
+}
+
+// Runtime information.
+type Runtime struct {
+	Uptime     string `json:"uptime"`
+	Goroutines int    `json:"goroutines"`
+	GOMAXPROCS int    `json:"gomaxprocs"`
+	NumCPU     int    `json:"num_cpu"`
+	HeapAlloc  uint64 `json:"heap_alloc"`
+	HeapSys    uint64 `json:"heap_sys"`
+	NumGC      uint32 `json:"num_gc"`
+	PauseTotal string `json:"gc_pause_total"`
+}
+
+func (s *Server) runtimeHandler(w http.ResponseWriter, r *http.Request) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	writeJSON(w, Runtime{
+		Uptime:     time.Since(s.started).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		NumCPU:     runtime.NumCPU(),
+		HeapAlloc:  ms.HeapAlloc,
+		HeapSys:    ms.HeapSys,
+		NumGC:      ms.NumGC,
+		PauseTotal: time.Duration(ms.PauseTotalNs).String(),
+	})
+}
+
+func (s *Server) configHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.config())
+}
+
+// PoolStats of the database connection pool.
+type PoolStats struct {
+	AcquireCount            int64  `json:"acquire_count"`
+	AcquireDuration         string `json:"acquire_duration"`
+	AcquiredConns           int32  `json:"acquired_conns"`
+	CanceledAcquireCount    int64  `json:"canceled_acquire_count"`
+	ConstructingConns       int32  `json:"constructing_conns"`
+	EmptyAcquireCount       int64  `json:"empty_acquire_count"`
+	IdleConns               int32  `json:"idle_conns"`
+	MaxConns                int32  `json:"max_conns"`
+	TotalConns              int32  `json:"total_conns"`
+	NewConnsCount           int64  `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64  `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64  `json:"max_idle_destroy_count"`
+}
+
+func (s *Server) poolHandler(w http.ResponseWriter, r *http.Request) {
+	stat := s.pool.Stat()
+	writeJSON(w, PoolStats{
+		AcquireCount:            stat.AcquireCount(),
+		AcquireDuration:         stat.AcquireDuration().String(),
+		AcquiredConns:           stat.AcquiredConns(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		ConstructingConns:       stat.ConstructingConns(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		IdleConns:               stat.IdleConns(),
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(v)
+}
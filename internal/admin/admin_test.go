@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	// Connections are established lazily, so a database isn't required.
+	pool, err := pgxpool.New(context.Background(), "host=localhost pool_max_conns=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	s := NewServer("", pool, func() any {
+		return map[string]string{"http": "localhost:8080"}
+	}, slog.Default())
+	hs := httptest.NewServer(s.handler())
+	defer hs.Close()
+
+	get := func(t *testing.T, path string, v any) {
+		t.Helper()
+		resp, err := hs.Client().Get(hs.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("GET %s: got status %d", path, resp.StatusCode)
+		}
+		if v == nil {
+			return
+		}
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("GET %s: cannot decode response: %v", path, err)
+		}
+	}
+
+	t.Run("version", func(t *testing.T) {
+		var v Version
+		get(t, "/version", &v)
+		if v.GoVersion != runtime.Version() {
+			t.Errorf("got Go version %q, want %q", v.GoVersion, runtime.Version())
+		}
+	})
+	t.Run("runtime", func(t *testing.T) {
+		var rt Runtime
+		get(t, "/runtime", &rt)
+		if rt.Goroutines == 0 || rt.NumCPU == 0 {
+			t.Errorf("unexpected runtime information: %+v", rt)
+		}
+	})
+	t.Run("config", func(t *testing.T) {
+		var config map[string]string
+		get(t, "/config", &config)
+		if config["http"] != "localhost:8080" {
+			t.Errorf("unexpected configuration: %v", config)
+		}
+	})
+	t.Run("pool", func(t *testing.T) {
+		var stats PoolStats
+		get(t, "/pool", &stats)
+		if stats.MaxConns != 7 {
+			t.Errorf("got max conns %d, want 7", stats.MaxConns)
+		}
+	})
+	t.Run("pprof", func(t *testing.T) {
+		get(t, "/debug/pprof/", nil)
+		get(t, "/debug/pprof/goroutine?debug=1", nil)
+	})
+	t.Run("expvar", func(t *testing.T) {
+		var vars map[string]any
+		get(t, "/debug/vars", &vars)
+		if _, ok := vars["memstats"]; !ok {
+			t.Error("missing memstats variable")
+		}
+	})
+}
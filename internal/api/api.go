@@ -1,17 +1,13 @@
 package api
 
 import (
-	"context"
 	"encoding/json"
-	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/henvic/vio"
 )
 
-// cacheControl policy for geolocation.
-var cacheControl = "max-age=3600, public"
-
 // APIError response.
 type APIError struct {
 	HTTPCode int    `json:"http_code"`
@@ -22,10 +18,25 @@ func (e APIError) Error() string {
 	return e.Message
 }
 
+// writeError writes an APIError response.
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(APIError{
+		HTTPCode: code,
+		Message:  message,
+	})
+}
+
 // lookupHandler handles the geolocation request to /v1/lookup.
+// The optional at query param returns the geolocation valid at that time.
 func (s *Server) lookupHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
-	w.Header().Add("Cache-control", cacheControl)
+	if cc := s.options().CacheControl; cc != "" {
+		w.Header().Add("Cache-control", cc)
+	}
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "\t")
 
@@ -38,10 +49,25 @@ func (s *Server) lookupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	location, err := s.service.LookupLocation(r.Context(), ip)
+	var (
+		location *vio.Geolocation
+		err      error
+	)
+	if v := r.URL.Query().Get("at"); v != "" {
+		at, perr := time.Parse(time.RFC3339, v)
+		if perr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			enc.Encode(APIError{
+				HTTPCode: http.StatusBadRequest,
+				Message:  "invalid at query param: must be an RFC 3339 timestamp",
+			})
+			return
+		}
+		location, err = s.service.LookupLocationAt(r.Context(), ip, at)
+	} else {
+		location, err = s.service.LookupLocation(r.Context(), ip)
+	}
 	switch {
-	case err == context.Canceled, err == context.DeadlineExceeded:
-		return
 	case err == vio.ErrBadIPAddressFormat:
 		w.WriteHeader(http.StatusBadRequest)
 		enc.Encode(APIError{
@@ -49,12 +75,9 @@ func (s *Server) lookupHandler(w http.ResponseWriter, r *http.Request) {
 			Message:  err.Error(),
 		})
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
-		enc.Encode(APIError{
-			HTTPCode: http.StatusInternalServerError,
-			Message:  http.StatusText(http.StatusInternalServerError),
-		})
-		s.log.LogAttrs(r.Context(), slog.LevelError, "internal server error getting location", slog.Any("error", err))
+		// Errors aren't cacheable.
+		w.Header().Del("Cache-control")
+		s.writeServiceError(w, r, err, "internal server error getting location")
 	case location == nil:
 		w.WriteHeader(http.StatusNotFound)
 		enc.Encode(APIError{
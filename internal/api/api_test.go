@@ -34,15 +34,16 @@ func TestLookup(t *testing.T) {
 	}
 	defer file.Close()
 
-	stats, err := vio.NewImporter(3, slog.Default(), pool).Stream(context.Background(), file)
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(context.Background(), file, vio.ImportSource{Name: "example.csv", Operator: "test"})
 	if stats == nil {
 		t.Error("stats should not be nil")
 	}
 	if err != nil {
 		t.Errorf("cannot import location data: %v", err)
 	}
+	runID := int64(1) // First import run of the test database.
 
-	s := NewServer("", vio.NewService(vio.NewPostgres(pool, slog.Default())), slog.Default())
+	s := NewServer("", vio.NewService(vio.NewPostgres(pool, slog.Default())), slog.Default(), Options{})
 	hs := httptest.NewServer(http.HandlerFunc(s.lookupHandler))
 
 	type args struct {
@@ -95,6 +96,9 @@ func TestLookup(t *testing.T) {
 				Latitude:    "-49.16675918861615",
 				Longitude:   "-86.05920084416894",
 				UpdatedAt:   time.Now(),
+				Source:      vio.SourceVendor,
+				RunID:       &runID,
+				Dataset:     "example.csv",
 			},
 		},
 		{
@@ -108,6 +112,9 @@ func TestLookup(t *testing.T) {
 				Latitude:    "-49.16675918861615",
 				Longitude:   "-86.05920084416894",
 				UpdatedAt:   time.Now(),
+				Source:      vio.SourceVendor,
+				RunID:       &runID,
+				Dataset:     "example.csv",
 			},
 		},
 	}
@@ -167,7 +174,7 @@ func FuzzLookup(f *testing.F) {
 	}
 	defer file.Close()
 
-	stats, err := vio.NewImporter(3, slog.Default(), pool).Stream(context.Background(), file)
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(context.Background(), file, vio.ImportSource{Name: "example.csv", Operator: "test"})
 	if stats == nil {
 		f.Error("stats should not be nil")
 	}
@@ -175,7 +182,7 @@ func FuzzLookup(f *testing.F) {
 		f.Errorf("cannot import location data: %v", err)
 	}
 
-	s := NewServer("", vio.NewService(vio.NewPostgres(pool, slog.Default())), slog.Default())
+	s := NewServer("", vio.NewService(vio.NewPostgres(pool, slog.Default())), slog.Default(), Options{})
 	hs := httptest.NewServer(http.HandlerFunc(s.lookupHandler))
 
 	f.Add("127.0.0.1")
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/henvic/vio"
+)
+
+type apiKeyContextKey struct{}
+
+// apiKeyFromContext returns the API key of the authenticated client, if any.
+func apiKeyFromContext(ctx context.Context) *vio.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*vio.APIKey)
+	return key
+}
+
+// requestAPIKey returns the API key sent on the X-API-Key header or as a bearer token.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// authenticate the client if an API key is sent.
+// Whether authentication is required is decided by requireScope.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		apiKey, err := s.service.AuthenticateAPIKey(r.Context(), key)
+		switch {
+		case err == vio.ErrInvalidAPIKey:
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+		case err != nil:
+			s.writeServiceError(w, r, err, "internal server error authenticating API key")
+		default:
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)))
+		}
+	})
+}
+
+// requireScope for accessing the handler.
+// If authentication isn't required, only the admin scope is enforced for anonymous clients.
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := apiKeyFromContext(r.Context())
+		switch {
+		case key == nil && (s.options().RequireAuth || scope == vio.ScopeAdmin):
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing API key")
+		case key != nil && !key.HasScope(scope):
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			writeError(w, http.StatusForbidden, "API key is missing the "+strconv.Quote(scope)+" scope")
+		default:
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuthentication(t *testing.T) {
+	t.Parallel()
+	lookupKey := &vio.APIKey{ID: 1, Name: "lookup", Scopes: []string{vio.ScopeLookup}}
+	adminKey := &vio.APIKey{ID: 2, Name: "admin", Scopes: []string{vio.ScopeAdmin}}
+
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+	tests := []struct {
+		name        string
+		requireAuth bool
+		scope       string
+		header      http.Header
+		mock        func(m *mock.MockDB)
+		wantCode    int
+		wantErr     *APIError
+	}{
+		{
+			name:     "anonymous_optional",
+			scope:    vio.ScopeLookup,
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:        "anonymous_required",
+			requireAuth: true,
+			scope:       vio.ScopeLookup,
+			wantCode:    http.StatusUnauthorized,
+			wantErr:     &APIError{HTTPCode: http.StatusUnauthorized, Message: "missing API key"},
+		},
+		{
+			name:     "anonymous_admin",
+			scope:    vio.ScopeAdmin,
+			wantCode: http.StatusUnauthorized,
+			wantErr:  &APIError{HTTPCode: http.StatusUnauthorized, Message: "missing API key"},
+		},
+		{
+			name:        "header",
+			requireAuth: true,
+			scope:       vio.ScopeLookup,
+			header:      http.Header{"X-Api-Key": {"vio_lookup"}},
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(lookupKey, nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:        "bearer",
+			requireAuth: true,
+			scope:       vio.ScopeAdmin,
+			header:      http.Header{"Authorization": {"Bearer vio_admin"}},
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(adminKey, nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "insufficient_scope",
+			scope:  vio.ScopeAdmin,
+			header: http.Header{"Authorization": {"Bearer vio_lookup"}},
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(lookupKey, nil)
+			},
+			wantCode: http.StatusForbidden,
+			wantErr:  &APIError{HTTPCode: http.StatusForbidden, Message: `API key is missing the "admin" scope`},
+		},
+		{
+			name:   "unknown_key",
+			scope:  vio.ScopeLookup,
+			header: http.Header{"X-Api-Key": {"vio_unknown"}},
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(nil, nil)
+			},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  &APIError{HTTPCode: http.StatusUnauthorized, Message: "invalid API key"},
+		},
+		{
+			name:     "malformed_key",
+			scope:    vio.ScopeLookup,
+			header:   http.Header{"X-Api-Key": {"secret"}},
+			wantCode: http.StatusUnauthorized,
+			wantErr:  &APIError{HTTPCode: http.StatusUnauthorized, Message: "invalid API key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			s := NewServer("", vio.NewService(m), slog.Default(), Options{RequireAuth: tt.requireAuth})
+			h := s.authenticate(s.requireScope(tt.scope, ok))
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/lookup", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantErr == nil {
+				return
+			}
+			var got *APIError
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("cannot decode API error: %v", err)
+			}
+			if !cmp.Equal(tt.wantErr, got) {
+				t.Errorf("API error mismatch: %v", cmp.Diff(tt.wantErr, got))
+			}
+			if w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Compression of responses negotiated with the Accept-Encoding header.
+type Compression struct {
+	// MinSize of the response body in bytes for it to be compressed.
+	// Smaller responses aren't worth the overhead.
+	MinSize int
+}
+
+// encoders of the supported content codings, in order of preference.
+var encoders = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{
+		name: "gzip",
+		pool: &sync.Pool{
+			New: func() any {
+				return gzip.NewWriter(io.Discard)
+			},
+		},
+	},
+}
+
+// encoder writes a compressed response.
+type encoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// negotiateEncoding returns the index of the preferred encoder accepted by the client, or -1 if none.
+//
+// Reference: https://www.rfc-editor.org/rfc/rfc9110#name-accept-encoding
+func negotiateEncoding(header string) int {
+	var (
+		best  = -1
+		bestQ float64
+		anyQ  = -1.0
+		qs    = make([]float64, len(encoders))
+	)
+	for i := range qs {
+		qs[i] = -1
+	}
+	for _, v := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(v, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				continue
+			}
+		}
+		if coding == "*" {
+			anyQ = q
+			continue
+		}
+		for i, e := range encoders {
+			if e.name == coding {
+				qs[i] = q
+			}
+		}
+	}
+	for i, q := range qs {
+		if q < 0 {
+			q = anyQ
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// compress responses when the client accepts a supported content coding.
+// Responses are buffered until MinSize bytes are written to decide whether to compress them.
+func (s *Server) compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := s.options().Compression
+		if c == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The response depends on the Accept-Encoding header even when not compressed,
+		// so caches must not serve a compressed response to clients not supporting it, or vice versa.
+		w.Header().Add("Vary", "Accept-Encoding")
+		i := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if i == -1 || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{
+			ResponseWriter: w,
+			name:           encoders[i].name,
+			pool:           encoders[i].pool,
+			minSize:        c.MinSize,
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter compresses the response body if it is large enough.
+type compressWriter struct {
+	http.ResponseWriter
+	name    string
+	pool    *sync.Pool
+	minSize int
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status != 0 || w.decided {
+		return
+	}
+	// Informational responses are sent straight away.
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// start writing the response, compressed or not, and the buffered body.
+func (w *compressWriter) start(large bool) error {
+	w.decided = true
+	h := w.ResponseWriter.Header()
+	if large && w.compressible() {
+		h.Set("Content-Encoding", w.name)
+		h.Del("Content-Length")
+		w.enc = w.pool.Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	if w.enc != nil {
+		_, err := w.enc.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// compressible checks whether the response should be compressed.
+func (w *compressWriter) compressible() bool {
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" || w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		return false
+	}
+	ct := h.Get("Content-Type")
+	return ct == "" || strings.HasPrefix(ct, "application/json") || strings.HasPrefix(ct, "text/")
+}
+
+// Flush the buffered response to the client.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		// The size of the response is unknown, so compress it anyway.
+		w.start(true)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack the connection, bypassing compression.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.decided = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Close writes the remaining response body.
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		return w.start(false)
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.enc.Reset(io.Discard)
+	w.pool.Put(w.enc)
+	w.enc = nil
+	return err
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{header: "", want: -1},
+		{header: "gzip", want: 0},
+		{header: "GZIP", want: 0},
+		{header: "deflate, gzip;q=0.5", want: 0},
+		{header: "gzip;q=0", want: -1},
+		{header: "br", want: -1},
+		{header: "*", want: 0},
+		{header: "*, gzip;q=0", want: -1},
+		{header: "identity", want: -1},
+		{header: "gzip;q=bad", want: -1},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %d, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+	large := strings.Repeat(`{"country_code":"NL"}`, 100)
+	s := NewServer("", nil, slog.Default(), Options{
+		Compression: &Compression{MinSize: 1024},
+	})
+	h := s.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.URL.Query().Get("body")
+		if ct := r.URL.Query().Get("type"); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.WriteHeader(http.StatusTeapot)
+		// Write in small chunks to exercise buffering.
+		for len(body) > 0 {
+			n := min(100, len(body))
+			io.WriteString(w, body[:n])
+			body = body[n:]
+		}
+	}))
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		body           string
+		contentType    string
+		wantEncoding   string
+	}{
+		{
+			name:           "large",
+			acceptEncoding: "gzip",
+			body:           large,
+			contentType:    "application/json",
+			wantEncoding:   "gzip",
+		},
+		{
+			name:           "small",
+			acceptEncoding: "gzip",
+			body:           "{}",
+			contentType:    "application/json",
+		},
+		{
+			name:        "not_accepted",
+			body:        large,
+			contentType: "application/json",
+		},
+		{
+			name:           "not_compressible",
+			acceptEncoding: "gzip",
+			body:           large,
+			contentType:    "image/png",
+		},
+		{
+			name:           "head",
+			method:         http.MethodHead,
+			acceptEncoding: "gzip",
+			body:           large,
+			contentType:    "application/json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/?body="+tt.body+"&type="+tt.contentType, nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			resp := w.Result()
+			if resp.StatusCode != http.StatusTeapot {
+				t.Errorf("status code = %d, want %d", resp.StatusCode, http.StatusTeapot)
+			}
+			if got := resp.Header.Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			if got := resp.Header.Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			body := resp.Body
+			if tt.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(resp.Body)
+				if err != nil {
+					t.Fatalf("cannot read compressed response: %v", err)
+				}
+				body = zr
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.body {
+				t.Errorf("response body has %d bytes, want %d", len(b), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressDisabled(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{})
+	h := s.compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 2048))
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Errorf("Vary = %q, want none", got)
+	}
+}
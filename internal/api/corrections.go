@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/henvic/vio"
+)
+
+// CorrectionRequest proposing a correction of the geolocation of an IP address.
+type CorrectionRequest struct {
+	IPAddress   string      `json:"ip_address"`
+	CountryCode string      `json:"country_code"`
+	Country     string      `json:"country"`
+	City        string      `json:"city"`
+	Latitude    json.Number `json:"latitude"`
+	Longitude   json.Number `json:"longitude"`
+	Comment     string      `json:"comment"`
+}
+
+func writeCorrection(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(v)
+}
+
+// submitCorrectionHandler handles the request to POST /v1/corrections to propose a correction.
+func (s *Server) submitCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	var req CorrectionRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	c, err := s.service.SubmitCorrection(r.Context(), req.IPAddress, vio.Correction{
+		CountryCode: req.CountryCode,
+		Country:     req.Country,
+		City:        req.City,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Comment:     req.Comment,
+		Reporter:    s.clientID(r),
+	})
+	if err != nil {
+		s.writeGeolocationError(w, r, err)
+		return
+	}
+	writeCorrection(w, http.StatusCreated, c)
+}
+
+// listCorrectionsHandler handles the request to GET /v1/admin/corrections.
+// The status query param filters the corrections: pending (default), approved, rejected, or all.
+func (s *Server) listCorrectionsHandler(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = vio.CorrectionPending
+	case "all":
+		status = ""
+	}
+	corrections, err := s.service.ListCorrections(r.Context(), status)
+	if err == vio.ErrInvalidCorrectionStatus {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		s.writeServiceError(w, r, err, "internal server error listing corrections")
+		return
+	}
+	if corrections == nil {
+		corrections = []vio.Correction{}
+	}
+	writeCorrection(w, http.StatusOK, corrections)
+}
+
+// approveCorrectionHandler handles the request to POST /v1/admin/corrections/{id}/approve.
+func (s *Server) approveCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	s.reviewCorrection(w, r, "correction.approve", s.service.ApproveCorrection)
+}
+
+// rejectCorrectionHandler handles the request to POST /v1/admin/corrections/{id}/reject.
+func (s *Server) rejectCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	s.reviewCorrection(w, r, "correction.reject", s.service.RejectCorrection)
+}
+
+func (s *Server) reviewCorrection(w http.ResponseWriter, r *http.Request, action string,
+	review func(ctx context.Context, id int64, reviewer string) (*vio.Correction, error)) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid correction ID")
+		return
+	}
+	c, err := review(r.Context(), id, s.clientID(r))
+	switch {
+	case err == vio.ErrCorrectionNotFound:
+		writeError(w, http.StatusNotFound, err.Error())
+	case err == vio.ErrCorrectionReviewed:
+		writeError(w, http.StatusConflict, err.Error())
+	case err != nil:
+		s.writeServiceError(w, r, err, "internal server error reviewing correction")
+	default:
+		s.audit(r, action, slog.Int64("correction_id", id), slog.String("ip", c.IPAddress.String()))
+		writeCorrection(w, http.StatusOK, c)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestCorrections(t *testing.T) {
+	t.Parallel()
+	adminKey := &vio.APIKey{ID: 1, Name: "support", Scopes: []string{vio.ScopeAdmin}}
+	reviewer := "key:1"
+	approved := &vio.Correction{
+		ID:        3,
+		IPAddress: net.ParseIP("192.0.2.1"),
+		City:      "Amsterdam",
+		Status:    vio.CorrectionApproved,
+		Reviewer:  &reviewer,
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		key      *vio.APIKey
+		body     string
+		mock     func(m *mock.MockDB)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:   "submit_anonymous",
+			method: http.MethodPost,
+			path:   "/v1/corrections",
+			body:   `{"ip_address":"192.0.2.1","country_code":"NL","city":"Amsterdam","comment":"Our office is in Amsterdam."}`,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().CreateCorrection(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, c *vio.Correction) error {
+					if c.Reporter != "ip:192.0.2.1" || c.Status != vio.CorrectionPending || c.City != "Amsterdam" {
+						t.Errorf("unexpected correction: %+v", c)
+					}
+					c.ID = 3
+					return nil
+				})
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `"Status": "pending"`,
+		},
+		{
+			name:     "submit_invalid",
+			method:   http.MethodPost,
+			path:     "/v1/corrections",
+			body:     `{"ip_address":"192.0.2.1"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "no useful data found",
+		},
+		{
+			name:     "list_anonymous",
+			method:   http.MethodGet,
+			path:     "/v1/admin/corrections",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:   "list_pending",
+			method: http.MethodGet,
+			path:   "/v1/admin/corrections",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().ListCorrections(gomock.Any(), vio.CorrectionPending).Return(nil, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: "[]",
+		},
+		{
+			name:     "list_bad_status",
+			method:   http.MethodGet,
+			path:     "/v1/admin/corrections?status=lost",
+			key:      adminKey,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "approve",
+			method: http.MethodPost,
+			path:   "/v1/admin/corrections/3/approve",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().ReviewCorrection(gomock.Any(), int64(3), vio.CorrectionApproved, "key:1").Return(approved, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"Status": "approved"`,
+		},
+		{
+			name:   "reject_reviewed",
+			method: http.MethodPost,
+			path:   "/v1/admin/corrections/3/reject",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().ReviewCorrection(gomock.Any(), int64(3), vio.CorrectionRejected, "key:1").Return(nil, vio.ErrCorrectionReviewed)
+			},
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:   "reject_not_found",
+			method: http.MethodPost,
+			path:   "/v1/admin/corrections/4/reject",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().ReviewCorrection(gomock.Any(), int64(4), vio.CorrectionRejected, "key:1").Return(nil, vio.ErrCorrectionNotFound)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "bad_id",
+			method:   http.MethodPost,
+			path:     "/v1/admin/corrections/x/approve",
+			key:      adminKey,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			if tt.key != nil {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(tt.key, nil)
+			}
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			s := NewServer("", vio.NewService(m), slog.Default(), Options{})
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.RemoteAddr = "192.0.2.1:1234"
+			if tt.key != nil {
+				r.Header.Set("X-API-Key", "vio_key")
+			}
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
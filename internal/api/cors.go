@@ -0,0 +1,125 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configuration for cross-origin requests from browsers.
+//
+// Reference: https://fetch.spec.whatwg.org/#http-cors-protocol
+type CORS struct {
+	// AllowedOrigins of the requests, e.g., https://example.com. Use * to allow any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods of the requests.
+	AllowedMethods []string
+
+	// AllowedHeaders of the requests. Use * to allow any header.
+	AllowedHeaders []string
+
+	// MaxAge for caching the preflight response.
+	MaxAge time.Duration
+}
+
+// exposedHeaders readable by browser clients.
+var exposedHeaders = strings.Join([]string{
+	"RateLimit-Limit",
+	"RateLimit-Remaining",
+	"RateLimit-Reset",
+	"Retry-After",
+}, ", ")
+
+// cors handles Cross-Origin Resource Sharing, including preflight requests.
+func (s *Server) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := s.options().CORS
+		if c == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if !c.allowOrigin(origin) {
+			if preflight {
+				writeError(w, http.StatusForbidden, "origin not allowed")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if slices.Contains(c.AllowedOrigins, "*") {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", exposedHeaders)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		method := r.Header.Get("Access-Control-Request-Method")
+		if !slices.Contains(c.AllowedMethods, method) {
+			writeError(w, http.StatusForbidden, "method not allowed")
+			return
+		}
+		headers, ok := c.allowHeaders(r.Header.Get("Access-Control-Request-Headers"))
+		if !ok {
+			writeError(w, http.StatusForbidden, "headers not allowed")
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowOrigin checks if the origin is allowed.
+func (c *CORS) allowOrigin(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowHeaders checks if the headers requested on a preflight request are allowed.
+// The headers are returned to be used as the Access-Control-Allow-Headers value.
+func (c *CORS) allowHeaders(requested string) (string, bool) {
+	if strings.TrimSpace(requested) == "" {
+		return "", true
+	}
+	if slices.Contains(c.AllowedHeaders, "*") {
+		return requested, true
+	}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if !slices.ContainsFunc(c.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, header)
+		}) {
+			return "", false
+		}
+	}
+	return requested, true
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{
+		CORS: &CORS{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{http.MethodGet, http.MethodHead},
+			AllowedHeaders: []string{"Authorization", "X-API-Key"},
+			MaxAge:         10 * time.Minute,
+		},
+	})
+	h := s.cors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		header     http.Header
+		wantCode   int
+		wantHeader http.Header
+	}{
+		{
+			name:     "same_origin",
+			method:   http.MethodGet,
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Vary": {"Origin"},
+			},
+		},
+		{
+			name:     "allowed",
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"https://app.example.com"}},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Vary":                          {"Origin"},
+				"Access-Control-Allow-Origin":   {"https://app.example.com"},
+				"Access-Control-Expose-Headers": {"RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After"},
+			},
+		},
+		{
+			name:     "not_allowed",
+			method:   http.MethodGet,
+			header:   http.Header{"Origin": {"https://evil.example.com"}},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Vary": {"Origin"},
+			},
+		},
+		{
+			name:   "preflight",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"x-api-key"},
+			},
+			wantCode: http.StatusNoContent,
+			wantHeader: http.Header{
+				"Vary":                         {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin":  {"https://app.example.com"},
+				"Access-Control-Allow-Methods": {"GET, HEAD"},
+				"Access-Control-Allow-Headers": {"x-api-key"},
+				"Access-Control-Max-Age":       {"600"},
+			},
+		},
+		{
+			name:   "preflight_bad_method",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"https://app.example.com"},
+				"Access-Control-Request-Method": {"DELETE"},
+			},
+			wantCode: http.StatusForbidden,
+			wantHeader: http.Header{
+				"Vary":                        {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin": {"https://app.example.com"},
+				"Content-Type":                {"application/json"},
+			},
+		},
+		{
+			name:   "preflight_bad_header",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                         {"https://app.example.com"},
+				"Access-Control-Request-Method":  {"GET"},
+				"Access-Control-Request-Headers": {"X-API-Key, Cookie"},
+			},
+			wantCode: http.StatusForbidden,
+			wantHeader: http.Header{
+				"Vary":                        {"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+				"Access-Control-Allow-Origin": {"https://app.example.com"},
+				"Content-Type":                {"application/json"},
+			},
+		},
+		{
+			name:   "preflight_bad_origin",
+			method: http.MethodOptions,
+			header: http.Header{
+				"Origin":                        {"https://evil.example.com"},
+				"Access-Control-Request-Method": {"GET"},
+			},
+			wantCode: http.StatusForbidden,
+			wantHeader: http.Header{
+				"Vary":         {"Origin"},
+				"Content-Type": {"application/json"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			r := httptest.NewRequest(tt.method, "/v1/lookup?ip=127.0.0.1", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantCode)
+			}
+			if diff := cmp.Diff(tt.wantHeader, w.Header()); diff != "" {
+				t.Errorf("headers mismatch: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/henvic/vio"
+)
+
+// maxRequestBody size in bytes accepted by the admin endpoints.
+const maxRequestBody = 64 << 10
+
+// GeolocationRequest to create or replace the geolocation of an IP address.
+type GeolocationRequest struct {
+	CountryCode string      `json:"country_code"`
+	Country     string      `json:"country"`
+	City        string      `json:"city"`
+	Latitude    json.Number `json:"latitude"`
+	Longitude   json.Number `json:"longitude"`
+}
+
+// GeolocationPatchRequest to change some fields of the geolocations of an IP address or network.
+// Omitted fields are left unchanged.
+type GeolocationPatchRequest struct {
+	CountryCode *string      `json:"country_code"`
+	Country     *string      `json:"country"`
+	City        *string      `json:"city"`
+	Latitude    *json.Number `json:"latitude"`
+	Longitude   *json.Number `json:"longitude"`
+}
+
+// LogValue of the fields to change, for the audit log.
+func (req GeolocationPatchRequest) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range []struct {
+		key   string
+		value *string
+	}{
+		{"country_code", req.CountryCode},
+		{"country", req.Country},
+		{"city", req.City},
+		{"latitude", (*string)(req.Latitude)},
+		{"longitude", (*string)(req.Longitude)},
+	} {
+		if f.value != nil {
+			attrs = append(attrs, slog.String(f.key, *f.value))
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// GeolocationsChangedResponse with the number of geolocations changed or deleted.
+type GeolocationsChangedResponse struct {
+	Network  string `json:"network"`
+	Affected int64  `json:"affected"`
+}
+
+// decodeRequest decodes the JSON request body, writing an error response if it is invalid.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeGeolocationError writes the response for an error changing geolocations.
+func (s *Server) writeGeolocationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case err == vio.ErrBadIPAddressFormat, err == vio.ErrBadNetworkFormat, errors.Is(err, vio.ErrInvalidGeolocation):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err == vio.ErrLocationNotFound:
+		writeError(w, http.StatusNotFound, "no location found for the given IP address or network")
+	default:
+		s.writeServiceError(w, r, err, "internal server error changing location")
+	}
+}
+
+// putGeolocationHandler handles the request to PUT /v1/admin/geolocations/{network...} to create or replace a geolocation.
+func (s *Server) putGeolocationHandler(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("network")
+	if strings.Contains(ip, "/") {
+		writeError(w, http.StatusBadRequest, "a geolocation can only be created or replaced for a single IP address")
+		return
+	}
+	var req GeolocationRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	location, created, err := s.service.PutLocation(r.Context(), ip, vio.Geolocation{
+		CountryCode: req.CountryCode,
+		Country:     req.Country,
+		City:        req.City,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+	})
+	if err != nil {
+		s.writeGeolocationError(w, r, err)
+		return
+	}
+	s.audit(r, "geolocation.put", slog.String("network", ip), slog.Bool("created", created), slog.Any("geolocation", req))
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(location)
+}
+
+// patchGeolocationsHandler handles the request to PATCH /v1/admin/geolocations/{network...}
+// to change the geolocations of an IP address or of all IP addresses within a CIDR network.
+func (s *Server) patchGeolocationsHandler(w http.ResponseWriter, r *http.Request) {
+	network := r.PathValue("network")
+	var req GeolocationPatchRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	n, err := s.service.UpdateLocations(r.Context(), network, vio.GeolocationPatch{
+		CountryCode: req.CountryCode,
+		Country:     req.Country,
+		City:        req.City,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+	})
+	if err != nil {
+		s.writeGeolocationError(w, r, err)
+		return
+	}
+	s.audit(r, "geolocation.patch", slog.String("network", network), slog.Int64("affected", n), slog.Any("changes", req))
+	writeGeolocationsChanged(w, network, n)
+}
+
+// deleteGeolocationsHandler handles the request to DELETE /v1/admin/geolocations/{network...}
+// to delete the geolocations of an IP address or of all IP addresses within a CIDR network.
+func (s *Server) deleteGeolocationsHandler(w http.ResponseWriter, r *http.Request) {
+	network := r.PathValue("network")
+	n, err := s.service.DeleteLocations(r.Context(), network)
+	if err != nil {
+		s.writeGeolocationError(w, r, err)
+		return
+	}
+	s.audit(r, "geolocation.delete", slog.String("network", network), slog.Int64("affected", n))
+	writeGeolocationsChanged(w, network, n)
+}
+
+func writeGeolocationsChanged(w http.ResponseWriter, network string, n int64) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(GeolocationsChangedResponse{
+		Network:  network,
+		Affected: n,
+	})
+}
+
+// audit logs a change made through the admin API, including who made it.
+func (s *Server) audit(r *http.Request, action string, attrs ...slog.Attr) {
+	attrs = append([]slog.Attr{
+		slog.String("action", action),
+		slog.String("client_ip", s.clientIP(r).String()),
+	}, attrs...)
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		attrs = append(attrs, slog.Int64("api_key_id", key.ID), slog.String("api_key_name", key.Name))
+	}
+	s.log.LogAttrs(r.Context(), slog.LevelInfo, "audit", attrs...)
+}
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestGeolocationsAdmin(t *testing.T) {
+	t.Parallel()
+	adminKey := &vio.APIKey{ID: 1, Name: "support", Scopes: []string{vio.ScopeAdmin}}
+	lookupKey := &vio.APIKey{ID: 2, Name: "web", Scopes: []string{vio.ScopeLookup}}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		key       *vio.APIKey
+		body      string
+		mock      func(m *mock.MockDB)
+		wantCode  int
+		wantBody  string
+		wantAudit string
+	}{
+		{
+			name:     "anonymous",
+			method:   http.MethodDelete,
+			path:     "/v1/admin/geolocations/192.0.2.1",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "insufficient_scope",
+			method:   http.MethodDelete,
+			path:     "/v1/admin/geolocations/192.0.2.1",
+			key:      lookupKey,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:   "put_created",
+			method: http.MethodPut,
+			path:   "/v1/admin/geolocations/192.0.2.1",
+			key:    adminKey,
+			body:   `{"country_code":"NL","country":"Netherlands","city":"Amsterdam","latitude":52.37,"longitude":"4.89"}`,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().PutLocation(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, loc *vio.Geolocation) (bool, error) {
+					if loc.IPAddress.String() != "192.0.2.1" || loc.City != "Amsterdam" || loc.Latitude != "52.37" {
+						t.Errorf("unexpected geolocation: %+v", loc)
+					}
+					loc.UpdatedAt = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+					return true, nil
+				})
+			},
+			wantCode: http.StatusCreated,
+			wantBody: `"City": "Amsterdam"`,
+		},
+		{
+			name:     "put_network",
+			method:   http.MethodPut,
+			path:     "/v1/admin/geolocations/192.0.2.0/24",
+			key:      adminKey,
+			body:     `{"country_code":"NL"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "put_invalid",
+			method:   http.MethodPut,
+			path:     "/v1/admin/geolocations/192.0.2.1",
+			key:      adminKey,
+			body:     `{"country_code":"nl"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid geolocation: country code must be an uppercase 2-letter ISO 3166-1 code",
+		},
+		{
+			name:     "put_unknown_field",
+			method:   http.MethodPut,
+			path:     "/v1/admin/geolocations/192.0.2.1",
+			key:      adminKey,
+			body:     `{"country_code":"NL","region":"North Holland"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "patch_network",
+			method: http.MethodPatch,
+			path:   "/v1/admin/geolocations/192.0.2.0/24",
+			key:    adminKey,
+			body:   `{"country":"Netherlands","city":"Rotterdam"}`,
+			mock: func(m *mock.MockDB) {
+				country, city := "Netherlands", "Rotterdam"
+				m.EXPECT().UpdateLocations(gomock.Any(), netip.MustParsePrefix("192.0.2.0/24"), vio.GeolocationPatch{Country: &country, City: &city}).Return(int64(3), nil)
+			},
+			wantCode:  http.StatusOK,
+			wantBody:  `"affected": 3`,
+			wantAudit: `action=geolocation.patch client_ip=192.0.2.1 network=192.0.2.0/24 affected=3 changes.country=Netherlands changes.city=Rotterdam api_key_id=1`,
+		},
+		{
+			name:     "patch_latitude_only",
+			method:   http.MethodPatch,
+			path:     "/v1/admin/geolocations/192.0.2.1",
+			key:      adminKey,
+			body:     `{"latitude":"10"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "latitude and longitude must be changed together",
+		},
+		{
+			name:     "patch_bad_network",
+			method:   http.MethodPatch,
+			path:     "/v1/admin/geolocations/192.0.2.1/24",
+			key:      adminKey,
+			body:     `{"city":"Rotterdam"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid IP address or CIDR network format",
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   "/v1/admin/geolocations/2001:db8::1",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().DeleteLocations(gomock.Any(), netip.MustParsePrefix("2001:db8::1/128")).Return(int64(1), nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"affected": 1`,
+		},
+		{
+			name:   "delete_not_found",
+			method: http.MethodDelete,
+			path:   "/v1/admin/geolocations/192.0.2.1",
+			key:    adminKey,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().DeleteLocations(gomock.Any(), netip.MustParsePrefix("192.0.2.1/32")).Return(int64(0), nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			if tt.key != nil {
+				m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(tt.key, nil)
+			}
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			var audit bytes.Buffer
+			s := NewServer("", vio.NewService(m), slog.New(slog.NewTextHandler(&audit, nil)), Options{})
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.key != nil {
+				r.Header.Set("X-API-Key", "vio_key")
+			}
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body, tt.wantBody)
+			}
+			if !strings.Contains(audit.String(), tt.wantAudit) {
+				t.Errorf("audit log = %s, want it to contain %q", &audit, tt.wantAudit)
+			}
+		})
+	}
+}
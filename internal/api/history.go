@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/henvic/vio"
+)
+
+// historyHandler handles the request to /v1/history for the versions of the geolocation of an IP address.
+func (s *Server) historyHandler(w http.ResponseWriter, r *http.Request) {
+	ip := r.URL.Query().Get("ip")
+	if ip == "" {
+		writeError(w, http.StatusBadRequest, "missing mandatory IP address query param")
+		return
+	}
+	versions, err := s.service.History(r.Context(), ip)
+	switch {
+	case err == vio.ErrBadIPAddressFormat:
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		s.writeServiceError(w, r, err, "internal server error getting history")
+		return
+	case len(versions) == 0:
+		writeError(w, http.StatusNotFound, "no history found for the given IP address")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if cc := s.options().CacheControl; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(versions)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLookupAt(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		query    string
+		mock     func(m *mock.MockDB)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:  "found",
+			query: "ip=192.0.2.1&at=2026-01-01T00:00:00Z",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().LookupLocationAt(gomock.Any(), net.ParseIP("192.0.2.1"), at).Return(&vio.Geolocation{
+					IPAddress: net.ParseIP("192.0.2.1"),
+					City:      "Amsterdam",
+					Source:    vio.SourceVendor,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"City": "Amsterdam"`,
+		},
+		{
+			name:  "not_found",
+			query: "ip=192.0.2.1&at=2026-01-01T00:00:00Z",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().LookupLocationAt(gomock.Any(), net.ParseIP("192.0.2.1"), at).Return(nil, nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "bad_at",
+			query:    "ip=192.0.2.1&at=yesterday",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid at query param",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			s := NewServer("", vio.NewService(m), slog.Default(), Options{})
+			w := httptest.NewRecorder()
+			s.lookupHandler(w, httptest.NewRequest(http.MethodGet, "/v1/lookup?"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	validTo := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		query    string
+		mock     func(m *mock.MockDB)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:  "found",
+			query: "ip=192.0.2.1",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetHistory(gomock.Any(), net.ParseIP("192.0.2.1"), 1000).Return([]vio.GeolocationVersion{
+					{IPAddress: net.ParseIP("192.0.2.1"), City: "Rotterdam", Source: vio.SourceVendor, ValidFrom: validTo},
+					{IPAddress: net.ParseIP("192.0.2.1"), City: "Amsterdam", Source: vio.SourceVendor, ValidTo: &validTo},
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"ValidTo": "2026-02-01T00:00:00Z"`,
+		},
+		{
+			name:  "not_found",
+			query: "ip=192.0.2.1",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().GetHistory(gomock.Any(), net.ParseIP("192.0.2.1"), 1000).Return(nil, nil)
+			},
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "missing_ip",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "bad_ip",
+			query:    "ip=x",
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid IP address format",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			s := NewServer("", vio.NewService(m), slog.Default(), Options{})
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/history?"+tt.query, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Listen on the given address, which might be:
+//
+//   - host:port for a TCP socket.
+//   - unix:/path/to/socket for a Unix domain socket.
+//   - systemd or systemd:name for a socket inherited from systemd socket activation.
+//
+// Reference for socket activation: https://www.freedesktop.org/software/systemd/man/latest/sd_listen_fds.html
+func Listen(address string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(address, "unix:"):
+		return listenUnix(strings.TrimPrefix(address, "unix:"))
+	case address == "systemd":
+		return listenSystemd("")
+	case strings.HasPrefix(address, "systemd:"):
+		return listenSystemd(strings.TrimPrefix(address, "systemd:"))
+	default:
+		if address == "" {
+			address = ":http"
+		}
+		return net.Listen("tcp", address)
+	}
+}
+
+// listenUnix listens on a Unix domain socket, replacing a stale socket file left by a previous process.
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("missing Unix socket path")
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode().Type() == fs.ModeSocket {
+		// Only remove the file if nobody is listening on it.
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// listenSystemd returns a listener passed by systemd using the LISTEN_FDS protocol.
+// If name is empty, the listener must be the only one passed.
+// Otherwise, it is matched against the FileDescriptorName of the socket unit (LISTEN_FDNAMES).
+func listenSystemd(name string) (net.Listener, error) {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_PID is not set to the process ID")
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("no sockets passed by systemd: LISTEN_FDS is not set")
+	}
+
+	i := 0
+	switch names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":"); {
+	case name == "" && n != 1:
+		return nil, fmt.Errorf("systemd passed %d sockets: use systemd:<name> to choose one", n)
+	case name != "":
+		i = -1
+		for pos, v := range names {
+			if v == name && pos < n {
+				i = pos
+				break
+			}
+		}
+		if i == -1 {
+			return nil, fmt.Errorf("no socket named %q passed by systemd", name)
+		}
+	}
+
+	f := os.NewFile(uintptr(listenFDsStart+i), "systemd:"+name)
+	defer f.Close()
+	return net.FileListener(f)
+}
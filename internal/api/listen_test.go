@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "vio.sock")
+	l, err := Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	if _, err := Listen("unix:" + path); err == nil || err.Error() != "unix socket "+path+" is already in use" {
+		t.Errorf("Listen() on socket in use error = %v", err)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("cannot connect to Unix socket: %v", err)
+	}
+	conn.Close()
+
+	// Simulate a stale socket file left by a process that crashed.
+	if ul, ok := l.(*net.UnixListener); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	l.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("socket file should be left behind: %v", err)
+	}
+	l, err = Listen("unix:" + path)
+	if err != nil {
+		t.Fatalf("Listen() should replace stale socket, got error = %v", err)
+	}
+	l.Close()
+
+	if _, err := Listen("unix:"); err == nil {
+		t.Error("Listen() should fail without socket path")
+	}
+}
+
+func TestListenSystemd(t *testing.T) {
+	pid := strconv.Itoa(os.Getpid())
+	tests := []struct {
+		name    string
+		address string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "not_activated",
+			address: "systemd",
+			wantErr: "no sockets passed by systemd: LISTEN_PID is not set to the process ID",
+		},
+		{
+			name:    "other_process",
+			address: "systemd",
+			env:     map[string]string{"LISTEN_PID": "1", "LISTEN_FDS": "1"},
+			wantErr: "no sockets passed by systemd: LISTEN_PID is not set to the process ID",
+		},
+		{
+			name:    "no_fds",
+			address: "systemd",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "0"},
+			wantErr: "no sockets passed by systemd: LISTEN_FDS is not set",
+		},
+		{
+			name:    "ambiguous",
+			address: "systemd",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http:admin"},
+			wantErr: "systemd passed 2 sockets: use systemd:<name> to choose one",
+		},
+		{
+			name:    "unknown_name",
+			address: "systemd:grpc",
+			env:     map[string]string{"LISTEN_PID": pid, "LISTEN_FDS": "2", "LISTEN_FDNAMES": "http:admin"},
+			wantErr: `no socket named "grpc" passed by systemd`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, k := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+				t.Setenv(k, tt.env[k])
+			}
+			l, err := Listen(tt.address)
+			if err == nil {
+				l.Close()
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Listen() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
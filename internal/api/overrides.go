@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/henvic/vio"
+)
+
+// OverrideRequest to create or replace the override of the geolocation of an IP address.
+type OverrideRequest struct {
+	CountryCode string      `json:"country_code"`
+	Country     string      `json:"country"`
+	City        string      `json:"city"`
+	Latitude    json.Number `json:"latitude"`
+	Longitude   json.Number `json:"longitude"`
+	Reason      string      `json:"reason"`
+}
+
+// writeOverrideError writes the response for an error managing overrides.
+func (s *Server) writeOverrideError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == vio.ErrOverrideNotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	s.writeGeolocationError(w, r, err)
+}
+
+// listOverridesHandler handles the request to GET /v1/admin/overrides.
+func (s *Server) listOverridesHandler(w http.ResponseWriter, r *http.Request) {
+	overrides, err := s.service.ListOverrides(r.Context())
+	if err != nil {
+		s.writeServiceError(w, r, err, "internal server error listing overrides")
+		return
+	}
+	if overrides == nil {
+		overrides = []vio.Override{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(overrides)
+}
+
+// putOverrideHandler handles the request to PUT /v1/admin/overrides/{ip} to create or replace an override.
+func (s *Server) putOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	var req OverrideRequest
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	override, err := s.service.SetOverride(r.Context(), ip, vio.Override{
+		CountryCode: req.CountryCode,
+		Country:     req.Country,
+		City:        req.City,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Reason:      req.Reason,
+		Author:      s.clientID(r),
+	})
+	if err != nil {
+		s.writeOverrideError(w, r, err)
+		return
+	}
+	s.audit(r, "override.put", slog.String("ip", ip), slog.Any("override", req))
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(override)
+}
+
+// deleteOverrideHandler handles the request to DELETE /v1/admin/overrides/{ip} to restore the vendor data.
+func (s *Server) deleteOverrideHandler(w http.ResponseWriter, r *http.Request) {
+	ip := r.PathValue("ip")
+	if err := s.service.DeleteOverride(r.Context(), ip); err != nil {
+		s.writeOverrideError(w, r, err)
+		return
+	}
+	s.audit(r, "override.delete", slog.String("ip", ip))
+	w.WriteHeader(http.StatusNoContent)
+}
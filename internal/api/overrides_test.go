@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestOverridesAdmin(t *testing.T) {
+	t.Parallel()
+	adminKey := &vio.APIKey{ID: 1, Name: "support", Scopes: []string{vio.ScopeAdmin}}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		mock     func(m *mock.MockDB)
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:   "put",
+			method: http.MethodPut,
+			path:   "/v1/admin/overrides/192.0.2.1",
+			body:   `{"country_code":"NL","country":"Netherlands","city":"Amsterdam","reason":"ticket 42"}`,
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().PutOverride(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, o *vio.Override) error {
+					if o.Author != "key:1" || o.Reason != "ticket 42" || o.City != "Amsterdam" {
+						t.Errorf("unexpected override: %+v", o)
+					}
+					return nil
+				})
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"Reason": "ticket 42"`,
+		},
+		{
+			name:     "put_missing_reason",
+			method:   http.MethodPut,
+			path:     "/v1/admin/overrides/192.0.2.1",
+			body:     `{"country_code":"NL"}`,
+			wantCode: http.StatusBadRequest,
+			wantBody: "invalid geolocation: missing reason",
+		},
+		{
+			name:   "list",
+			method: http.MethodGet,
+			path:   "/v1/admin/overrides",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().ListOverrides(gomock.Any()).Return([]vio.Override{{
+					IPAddress:   net.ParseIP("192.0.2.1"),
+					CountryCode: "NL",
+					Reason:      "ticket 42",
+					Author:      "key:1",
+				}}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"Author": "key:1"`,
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			path:   "/v1/admin/overrides/192.0.2.1",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().DeleteOverride(gomock.Any(), net.ParseIP("192.0.2.1")).Return(nil)
+			},
+			wantCode: http.StatusNoContent,
+		},
+		{
+			name:   "delete_not_found",
+			method: http.MethodDelete,
+			path:   "/v1/admin/overrides/192.0.2.1",
+			mock: func(m *mock.MockDB) {
+				m.EXPECT().DeleteOverride(gomock.Any(), net.ParseIP("192.0.2.1")).Return(vio.ErrOverrideNotFound)
+			},
+			wantCode: http.StatusNotFound,
+			wantBody: "override not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mock.NewMockDB(gomock.NewController(t))
+			m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(adminKey, nil)
+			if tt.mock != nil {
+				tt.mock(m)
+			}
+			s := NewServer("", vio.NewService(m), slog.Default(), Options{})
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("X-API-Key", "vio_key")
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", w.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/henvic/vio/internal/ratelimit"
+)
+
+// RateLimit configuration for the API.
+type RateLimit struct {
+	// Limit for each client.
+	Limit ratelimit.Limit
+
+	// Store for the token buckets.
+	Store ratelimit.Store
+}
+
+// rateLimit requests per client IP address. It runs before authentication,
+// so requests with invalid API keys are limited too, and don't reach the database past the limit.
+// A request exceeding the limit is rejected with HTTP 429 Too Many Requests.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.takeToken(w, r, "ip:"+s.clientIP(r).String()) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// rateLimitKey requests per API key of the authenticated clients, which might use many IP addresses.
+func (s *Server) rateLimitKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := apiKeyFromContext(r.Context())
+		if key == nil || s.takeToken(w, r, "key:"+strconv.FormatInt(key.ID, 10)) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// takeToken from the bucket of the client, writing an error response if the limit was exceeded.
+func (s *Server) takeToken(w http.ResponseWriter, r *http.Request, client string) bool {
+	rl := s.options().RateLimit
+	if rl == nil {
+		return true
+	}
+	res, err := rl.Store.Take(r.Context(), client, rl.Limit, time.Now())
+	if err != nil {
+		// Fail open: an unavailable rate limiter shouldn't take the API down.
+		s.log.LogAttrs(r.Context(), slog.LevelError, "cannot check rate limit", slog.Any("error", err))
+		return true
+	}
+
+	// Reference: https://datatracker.ietf.org/doc/draft-ietf-httpapi-ratelimit-headers/
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+	if !res.Allowed {
+		h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// ceilSeconds formats a duration as a number of seconds, rounding up.
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// clientID identifies the client making the request by its API key or IP address.
+func (s *Server) clientID(r *http.Request) string {
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		return "key:" + strconv.FormatInt(key.ID, 10)
+	}
+	return "ip:" + s.clientIP(r).String()
+}
+
+// clientIP returns the IP address of the client.
+// The X-Forwarded-For header is only considered when the request comes from a trusted proxy.
+// In this case, it's read from right to left, skipping trusted proxies.
+func (s *Server) clientIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+	if !s.trustedProxy(addr) {
+		return addr
+	}
+
+	xff := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(xff) - 1; i >= 0; i-- {
+		a, err := netip.ParseAddr(strings.TrimSpace(xff[i]))
+		if err != nil {
+			// Stop on the first value that can't be trusted to be an address.
+			break
+		}
+		addr = a.Unmap()
+		if !s.trustedProxy(addr) {
+			break
+		}
+	}
+	return addr
+}
+
+// trustedProxy checks whether the address belongs to a trusted proxy.
+func (s *Server) trustedProxy(addr netip.Addr) bool {
+	for _, p := range s.options().TrustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
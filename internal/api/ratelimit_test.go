@@ -0,0 +1,151 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"github.com/henvic/vio/internal/ratelimit"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{
+		RateLimit: &RateLimit{
+			Limit: ratelimit.Limit{Rate: 0.5, Burst: 2},
+			Store: ratelimit.NewMemory(),
+		},
+	})
+	h := s.rateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		remoteAddr    string
+		wantCode      int
+		wantRemaining string
+		wantRetry     string
+	}{
+		{"192.0.2.1:1234", http.StatusNoContent, "1", ""},
+		{"192.0.2.1:1235", http.StatusNoContent, "0", ""},
+		{"192.0.2.1:1236", http.StatusTooManyRequests, "0", "2"},
+		{"192.0.2.2:1234", http.StatusNoContent, "1", ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/v1/lookup?ip=127.0.0.1", nil)
+		r.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: got status %d, want %d", tt.remoteAddr, w.Code, tt.wantCode)
+		}
+		if got := w.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("%s: got RateLimit-Limit %q, want 2", tt.remoteAddr, got)
+		}
+		if got := w.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+			t.Errorf("%s: got RateLimit-Remaining %q, want %q", tt.remoteAddr, got, tt.wantRemaining)
+		}
+		if got := w.Header().Get("Retry-After"); got != tt.wantRetry {
+			t.Errorf("%s: got Retry-After %q, want %q", tt.remoteAddr, got, tt.wantRetry)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{
+		TrustedProxies: []netip.Prefix{
+			netip.MustParsePrefix("10.0.0.0/8"),
+			netip.MustParsePrefix("2001:db8::/32"),
+		},
+	})
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		want       string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "192.0.2.1:1234",
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "untrusted_proxy",
+			remoteAddr: "192.0.2.1:1234",
+			xff:        []string{"198.51.100.1"},
+			want:       "192.0.2.1",
+		},
+		{
+			name:       "trusted_proxy",
+			remoteAddr: "10.0.0.1:1234",
+			xff:        []string{"198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "spoofed",
+			remoteAddr: "10.0.0.1:1234",
+			xff:        []string{"203.0.113.7, 198.51.100.1", "10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "ipv6_proxy",
+			remoteAddr: "[2001:db8::1]:1234",
+			xff:        []string{"::ffff:198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "only_proxies",
+			remoteAddr: "10.0.0.1:1234",
+			xff:        []string{"10.0.0.3"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "garbage",
+			remoteAddr: "10.0.0.1:1234",
+			xff:        []string{"unknown"},
+			want:       "10.0.0.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := s.clientIP(r).String(); got != tt.want {
+				t.Errorf("Server.clientIP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitInvalidAPIKeys(t *testing.T) {
+	t.Parallel()
+	m := mock.NewMockDB(gomock.NewController(t))
+	// Requests past the limit don't reach the database.
+	m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+	s := NewServer("", vio.NewService(m), slog.Default(), Options{
+		RateLimit: &RateLimit{
+			Limit: ratelimit.Limit{Rate: 0.5, Burst: 2},
+			Store: ratelimit.NewMemory(),
+		},
+	})
+	h := s.handler()
+	for n, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/lookup?ip=127.0.0.1", nil)
+		r.Header.Set("X-API-Key", "vio_made_up_"+strconv.Itoa(n))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: got status %d, want %d", n, w.Code, want)
+		}
+	}
+}
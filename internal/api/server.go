@@ -4,18 +4,55 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"net/netip"
+	"sync/atomic"
 	"time"
 
 	"github.com/henvic/vio"
 )
 
 // NewServer creates a new API server.
-func NewServer(address string, service *vio.Service, log *slog.Logger) *Server {
-	return &Server{
+func NewServer(address string, service *vio.Service, log *slog.Logger, opts Options) *Server {
+	s := &Server{
 		address: address,
 		service: service,
 		log:     log,
 	}
+	s.opts.Store(&opts)
+	return s
+}
+
+// Options for the API server.
+type Options struct {
+	// CacheControl policy for geolocation responses.
+	CacheControl string
+
+	// ReadHeaderTimeout for reading request headers. Defaults to 5 seconds.
+	ReadHeaderTimeout time.Duration
+
+	// RequestTimeout for handling a request, after which it fails with 503 Service Unavailable. Disabled if zero.
+	RequestTimeout time.Duration
+
+	// RequireAuth rejects requests without a valid API key.
+	RequireAuth bool
+
+	// RateLimit requests per client. Disabled if nil.
+	RateLimit *RateLimit
+
+	// Usage tracks lookups of each client and enforces monthly quotas. Disabled if nil.
+	Usage *vio.UsageTracker
+
+	// CORS for browser clients. Disabled if nil.
+	CORS *CORS
+
+	// Compression of responses. Disabled if nil.
+	Compression *Compression
+
+	// TLS for serving HTTPS. Plain HTTP is used if nil.
+	TLS *TLS
+
+	// TrustedProxies whose X-Forwarded-For header is used to identify clients.
+	TrustedProxies []netip.Prefix
 }
 
 // Server for the API.
@@ -23,27 +60,104 @@ type Server struct {
 	address string
 	service *vio.Service
 	log     *slog.Logger
+	opts    atomic.Pointer[Options]
 	http    *http.Server
 }
 
+// options of the server.
+func (s *Server) options() *Options {
+	return s.opts.Load()
+}
+
+// Reload the options that can be changed while the server is running:
+// CacheControl, RequestTimeout, TrustedProxies, RateLimit, and CORS. Other options are ignored.
+func (s *Server) Reload(opts Options) {
+	next := *s.options()
+	next.CacheControl = opts.CacheControl
+	next.RequestTimeout = opts.RequestTimeout
+	next.TrustedProxies = opts.TrustedProxies
+	next.CORS = opts.CORS
+	switch {
+	case opts.RateLimit == nil || next.RateLimit == nil:
+		next.RateLimit = opts.RateLimit
+	default:
+		// Keep the current token buckets.
+		next.RateLimit = &RateLimit{
+			Limit: opts.RateLimit.Limit,
+			Store: next.RateLimit.Store,
+		}
+	}
+	s.opts.Store(&next)
+}
+
 // Run starts the HTTP server.
 func (s *Server) Run(ctx context.Context) (err error) {
-	mux := http.NewServeMux()
-	mux.HandleFunc("GET /v1/lookup", s.lookupHandler)
-
+	opts := s.options()
+	readHeaderTimeout := opts.ReadHeaderTimeout
+	if readHeaderTimeout == 0 {
+		readHeaderTimeout = 5 * time.Second
+	}
 	s.http = &http.Server{
 		Addr:    s.address,
-		Handler: mux,
+		Handler: s.handler(),
+
+		ReadHeaderTimeout: readHeaderTimeout, // mitigate risk of Slowloris Attack
+	}
+	var cr *certReloader
+	if opts.TLS != nil {
+		if cr, err = newCertReloader(opts.TLS, s.log); err != nil {
+			return err
+		}
+	}
 
-		ReadHeaderTimeout: 5 * time.Second, // mitigate risk of Slowloris Attack
+	l, err := Listen(s.address)
+	if err != nil {
+		return err
 	}
-	s.log.Info("HTTP server listening", slog.Any("address", s.address))
-	if err := s.http.ListenAndServe(); err != http.ErrServerClosed {
+	if cr == nil {
+		s.log.Info("HTTP server listening", slog.Any("address", l.Addr()))
+		if err := s.http.Serve(l); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go cr.run(ctx)
+
+	s.http.TLSConfig = cr.tlsConfig()
+	s.log.Info("HTTPS server listening",
+		slog.Any("address", l.Addr()),
+		slog.Bool("mtls", opts.TLS.ClientCAFile != ""),
+	)
+	if err := s.http.ServeTLS(l, "", ""); err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// handler for the API routes.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/lookup", s.requireScope(vio.ScopeLookup, s.meter(s.lookupHandler)))
+	mux.HandleFunc("PUT /v1/admin/geolocations/{network...}", s.requireScope(vio.ScopeAdmin, s.putGeolocationHandler))
+	mux.HandleFunc("PATCH /v1/admin/geolocations/{network...}", s.requireScope(vio.ScopeAdmin, s.patchGeolocationsHandler))
+	mux.HandleFunc("DELETE /v1/admin/geolocations/{network...}", s.requireScope(vio.ScopeAdmin, s.deleteGeolocationsHandler))
+	mux.HandleFunc("GET /v1/history", s.requireScope(vio.ScopeLookup, s.historyHandler))
+	mux.HandleFunc("POST /v1/corrections", s.requireScope(vio.ScopeLookup, s.submitCorrectionHandler))
+	mux.HandleFunc("GET /v1/admin/corrections", s.requireScope(vio.ScopeAdmin, s.listCorrectionsHandler))
+	mux.HandleFunc("POST /v1/admin/corrections/{id}/approve", s.requireScope(vio.ScopeAdmin, s.approveCorrectionHandler))
+	mux.HandleFunc("POST /v1/admin/corrections/{id}/reject", s.requireScope(vio.ScopeAdmin, s.rejectCorrectionHandler))
+	mux.HandleFunc("GET /v1/admin/overrides", s.requireScope(vio.ScopeAdmin, s.listOverridesHandler))
+	mux.HandleFunc("PUT /v1/admin/overrides/{ip}", s.requireScope(vio.ScopeAdmin, s.putOverrideHandler))
+	mux.HandleFunc("DELETE /v1/admin/overrides/{ip}", s.requireScope(vio.ScopeAdmin, s.deleteOverrideHandler))
+	if s.options().Usage != nil {
+		mux.HandleFunc("GET /v1/usage", s.requireScope(vio.ScopeLookup, s.usageHandler))
+	}
+	return s.compress(s.cors(s.timeout(s.rateLimit(s.authenticate(s.rateLimitKey(mux))))))
+}
+
 // Shutdown HTTP server.
 func (s *Server) Shutdown(ctx context.Context) {
 	s.log.Info("shutting down HTTP server gracefully")
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/henvic/vio/internal/ratelimit"
+)
+
+func TestServerReload(t *testing.T) {
+	t.Parallel()
+	store := ratelimit.NewMemory()
+	s := NewServer("", nil, slog.Default(), Options{
+		CacheControl: "max-age=3600, public",
+		RequireAuth:  true,
+		RateLimit: &RateLimit{
+			Limit: ratelimit.Limit{Rate: 1, Burst: 5},
+			Store: store,
+		},
+	})
+	s.Reload(Options{
+		CacheControl: "no-cache",
+		RateLimit: &RateLimit{
+			Limit: ratelimit.Limit{Rate: 2, Burst: 10},
+			Store: ratelimit.NewMemory(),
+		},
+		CORS: &CORS{AllowedOrigins: []string{"*"}},
+	})
+
+	got := s.options()
+	if got.CacheControl != "no-cache" {
+		t.Errorf("CacheControl = %q, want no-cache", got.CacheControl)
+	}
+	if !got.RequireAuth {
+		t.Error("RequireAuth should not be reloaded")
+	}
+	if got.RateLimit.Limit != (ratelimit.Limit{Rate: 2, Burst: 10}) {
+		t.Errorf("RateLimit.Limit = %+v, want rate 2 and burst 10", got.RateLimit.Limit)
+	}
+	if got.RateLimit.Store != store {
+		t.Error("RateLimit.Store should be kept when reloading")
+	}
+	if got.CORS == nil {
+		t.Error("CORS should be enabled")
+	}
+
+	s.Reload(Options{})
+	if got := s.options(); got.RateLimit != nil || got.CORS != nil {
+		t.Error("RateLimit and CORS should be disabled")
+	}
+}
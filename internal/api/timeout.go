@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+
+	"github.com/henvic/vio"
+)
+
+// timeouts counts requests that timed out, by the request deadline ("request") or the database statement timeout ("db").
+// It is published on /debug/vars of the admin server.
+var timeouts = expvar.NewMap("api_timeouts")
+
+// timeout sets a deadline for handling the request.
+func (s *Server) timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := s.options().RequestTimeout
+		if d <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// writeServiceError writes the response for an error returned by the service.
+// Nothing is written if the client is gone.
+func (s *Server) writeServiceError(w http.ResponseWriter, r *http.Request, err error, msg string) {
+	switch {
+	case errors.Is(err, vio.ErrQueryTimeout):
+		timeouts.Add("db", 1)
+		s.log.LogAttrs(r.Context(), slog.LevelWarn, "database query timed out", slog.String("path", r.URL.Path))
+		writeError(w, http.StatusGatewayTimeout, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		timeouts.Add("request", 1)
+		s.log.LogAttrs(r.Context(), slog.LevelWarn, "request timed out", slog.String("path", r.URL.Path))
+		writeError(w, http.StatusServiceUnavailable, "request timed out")
+	case errors.Is(err, context.Canceled):
+	default:
+		s.log.LogAttrs(r.Context(), slog.LevelError, msg, slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/henvic/vio"
+)
+
+func TestTimeout(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{
+		RequestTimeout: time.Millisecond,
+	})
+	h := s.timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		s.writeServiceError(w, r, r.Context().Err(), "unexpected")
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/lookup", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestWriteServiceError(t *testing.T) {
+	t.Parallel()
+	s := NewServer("", nil, slog.Default(), Options{})
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "query_timeout",
+			err:      vio.ErrQueryTimeout,
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: "{\n\t\"http_code\": 504,\n\t\"message\": \"database query timed out\"\n}\n",
+		},
+		{
+			name:     "deadline",
+			err:      context.DeadlineExceeded,
+			wantCode: http.StatusServiceUnavailable,
+			wantBody: "{\n\t\"http_code\": 503,\n\t\"message\": \"request timed out\"\n}\n",
+		},
+		{
+			name:     "canceled",
+			err:      context.Canceled,
+			wantCode: http.StatusOK, // Nothing written.
+		},
+		{
+			name:     "internal",
+			err:      errors.New("cannot get location from database"),
+			wantCode: http.StatusInternalServerError,
+			wantBody: "{\n\t\"http_code\": 500,\n\t\"message\": \"Internal Server Error\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			before := timeouts.String()
+			w := httptest.NewRecorder()
+			s.writeServiceError(w, httptest.NewRequest(http.MethodGet, "/v1/lookup", nil), tt.err, "error")
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if tt.wantCode >= http.StatusServiceUnavailable && timeouts.String() == before {
+				t.Error("timeout should be counted")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// TLS configuration for serving HTTPS.
+type TLS struct {
+	// CertFile and KeyFile with the PEM encoded certificate chain and private key.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile with a PEM encoded CA bundle for verifying client certificates (mutual TLS).
+	// Client certificates are not requested if empty.
+	ClientCAFile string
+
+	// ReloadInterval for checking if the files changed, so they're reloaded without restarting the server.
+	ReloadInterval time.Duration
+}
+
+// certReloader keeps the TLS certificates up-to-date with the files on disk.
+type certReloader struct {
+	c   *TLS
+	log *slog.Logger
+
+	// current TLS configuration for new connections.
+	current atomic.Pointer[tls.Config]
+
+	// mod times of the files when they were last loaded, used to detect changes.
+	mod map[string]time.Time
+}
+
+// newCertReloader loads the certificates and returns a reloader for them.
+func newCertReloader(c *TLS, log *slog.Logger) (*certReloader, error) {
+	cr := &certReloader{
+		c:   c,
+		log: log,
+	}
+	if err := cr.load(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// files watched for changes.
+func (cr *certReloader) files() []string {
+	files := []string{cr.c.CertFile, cr.c.KeyFile}
+	if cr.c.ClientCAFile != "" {
+		files = append(files, cr.c.ClientCAFile)
+	}
+	return files
+}
+
+// modTimes of the watched files.
+func (cr *certReloader) modTimes() (map[string]time.Time, error) {
+	mod := map[string]time.Time{}
+	for _, name := range cr.files() {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		mod[name] = fi.ModTime()
+	}
+	return mod, nil
+}
+
+// load the certificates from disk.
+func (cr *certReloader) load() error {
+	mod, err := cr.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.c.CertFile, cr.c.KeyFile)
+	if err != nil {
+		return fmt.Errorf("cannot load TLS certificate: %w", err)
+	}
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   nextProtos,
+	}
+	if cr.c.ClientCAFile != "" {
+		b, err := os.ReadFile(cr.c.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("cannot load client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return errors.New("cannot load client CA bundle: no certificates found")
+		}
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	cr.current.Store(conf)
+	cr.mod = mod
+	return nil
+}
+
+// reload the certificates if any file changed.
+// The current certificates are kept if the new ones cannot be loaded.
+func (cr *certReloader) reload() {
+	mod, err := cr.modTimes()
+	if err != nil {
+		cr.log.Error("cannot check TLS files for changes", slog.Any("error", err))
+		return
+	}
+	changed := false
+	for name, t := range mod {
+		if !t.Equal(cr.mod[name]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	if err := cr.load(); err != nil {
+		cr.log.Error("cannot reload TLS certificates", slog.Any("error", err))
+		return
+	}
+	cr.log.Info("TLS certificates reloaded")
+}
+
+// run reloads the certificates periodically until the context is canceled.
+func (cr *certReloader) run(ctx context.Context) {
+	interval := cr.c.ReloadInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cr.reload()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// nextProtos negotiated with the clients, as http.Server.ServeTLS sets them for HTTP/2.
+var nextProtos = []string{"h2", "http/1.1"}
+
+// tlsConfig for the HTTP server, always using the latest certificates.
+// The client CAs can only be changed by replacing the configuration of the connections,
+// so the current one, with the same settings, is used instead for mutual TLS.
+func (cr *certReloader) tlsConfig() *tls.Config {
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &cr.current.Load().Certificates[0], nil
+		},
+	}
+	if cr.c.ClientCAFile != "" {
+		conf.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return cr.current.Load(), nil
+		}
+	}
+	return conf
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate signed by parent, or self-signed if parent is nil.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t testing.TB, cn string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeFile and set its modification time, as the file system time resolution might be too coarse.
+func writeFile(t testing.TB, name string, data []byte, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(name, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	t.Parallel()
+	var (
+		dir      = t.TempDir()
+		certFile = filepath.Join(dir, "cert.pem")
+		keyFile  = filepath.Join(dir, "key.pem")
+		now      = time.Now()
+		first    = newTestCert(t, "first", nil)
+		second   = newTestCert(t, "second", nil)
+	)
+	writeFile(t, certFile, first.certPEM, now.Add(-time.Hour))
+	writeFile(t, keyFile, first.keyPEM, now.Add(-time.Hour))
+
+	cr, err := newCertReloader(&TLS{CertFile: certFile, KeyFile: keyFile}, slog.Default())
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	subject := func() string {
+		cert, err := cr.tlsConfig().GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if got := subject(); got != "first" {
+		t.Errorf("got certificate %q, want first", got)
+	}
+
+	// A half-rotated pair must not replace the working certificate.
+	writeFile(t, certFile, second.certPEM, now)
+	cr.reload()
+	if got := subject(); got != "first" {
+		t.Errorf("got certificate %q after partial rotation, want first", got)
+	}
+
+	writeFile(t, keyFile, second.keyPEM, now)
+	cr.reload()
+	if got := subject(); got != "second" {
+		t.Errorf("got certificate %q after rotation, want second", got)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+	var (
+		dir          = t.TempDir()
+		certFile     = filepath.Join(dir, "cert.pem")
+		keyFile      = filepath.Join(dir, "key.pem")
+		clientCAFile = filepath.Join(dir, "ca.pem")
+		now          = time.Now()
+		ca           = newTestCert(t, "ca", nil)
+		server       = newTestCert(t, "server", ca)
+		client       = newTestCert(t, "client", ca)
+		stranger     = newTestCert(t, "stranger", nil)
+	)
+	writeFile(t, certFile, server.certPEM, now)
+	writeFile(t, keyFile, server.keyPEM, now)
+	writeFile(t, clientCAFile, ca.certPEM, now)
+
+	cr, err := newCertReloader(&TLS{CertFile: certFile, KeyFile: keyFile, ClientCAFile: clientCAFile}, slog.Default())
+	if err != nil {
+		t.Fatalf("newCertReloader() error = %v", err)
+	}
+	hs := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	hs.TLS = cr.tlsConfig()
+	hs.StartTLS()
+	defer hs.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(c *testCert) error {
+		conf := &tls.Config{RootCAs: roots}
+		if c != nil {
+			conf.Certificates = []tls.Certificate{{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}}
+		}
+		hc := &http.Client{Transport: &http.Transport{TLSClientConfig: conf}}
+		resp, err := hc.Get(hs.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get(client); err != nil {
+		t.Errorf("request with valid client certificate failed: %v", err)
+	}
+	if err := get(stranger); err == nil {
+		t.Error("request with untrusted client certificate should fail")
+	}
+	if err := get(nil); err == nil {
+		t.Error("request without client certificate should fail")
+	}
+}
+
+func TestTLSHTTP2(t *testing.T) {
+	t.Parallel()
+	var (
+		dir          = t.TempDir()
+		certFile     = filepath.Join(dir, "cert.pem")
+		keyFile      = filepath.Join(dir, "key.pem")
+		clientCAFile = filepath.Join(dir, "ca.pem")
+		now          = time.Now()
+		ca           = newTestCert(t, "ca", nil)
+		server       = newTestCert(t, "server", ca)
+		client       = newTestCert(t, "client", ca)
+	)
+	writeFile(t, certFile, server.certPEM, now)
+	writeFile(t, keyFile, server.keyPEM, now)
+	writeFile(t, clientCAFile, ca.certPEM, now)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	for _, mtls := range []bool{false, true} {
+		c := &TLS{CertFile: certFile, KeyFile: keyFile}
+		if mtls {
+			c.ClientCAFile = clientCAFile
+		}
+		cr, err := newCertReloader(c, slog.Default())
+		if err != nil {
+			t.Fatalf("newCertReloader() error = %v", err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		hs := &http.Server{
+			Handler:   http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			TLSConfig: cr.tlsConfig(),
+		}
+		go hs.ServeTLS(l, "", "")
+
+		conf := &tls.Config{RootCAs: roots}
+		if mtls {
+			conf.Certificates = []tls.Certificate{{Certificate: [][]byte{client.cert.Raw}, PrivateKey: client.key}}
+		}
+		hc := &http.Client{Transport: &http.Transport{TLSClientConfig: conf, ForceAttemptHTTP2: true}}
+		resp, err := hc.Get("https://" + l.Addr().String())
+		if err != nil {
+			t.Fatalf("request with mtls = %v failed: %v", mtls, err)
+		}
+		resp.Body.Close()
+		if got := resp.TLS.NegotiatedProtocol; got != "h2" {
+			t.Errorf("negotiated protocol with mtls = %v is %q, want h2", mtls, got)
+		}
+		hs.Close()
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// UsageResponse with the API usage of a client during the current month.
+type UsageResponse struct {
+	Client       string       `json:"client"`
+	Month        string       `json:"month"`
+	Lookups      int64        `json:"lookups"`
+	MonthlyQuota *int64       `json:"monthly_quota,omitempty"`
+	Days         []DailyUsage `json:"days"`
+}
+
+// DailyUsage of the API.
+type DailyUsage struct {
+	Day     string `json:"day"`
+	Lookups int64  `json:"lookups"`
+}
+
+// meter counts the lookups of each client and enforces the monthly quota of API keys.
+func (s *Server) meter(next http.HandlerFunc) http.HandlerFunc {
+	tracker := s.options().Usage
+	if tracker == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			client = s.clientID(r)
+			now    = time.Now()
+		)
+		if key := apiKeyFromContext(r.Context()); key != nil && key.MonthlyQuota != nil {
+			lookups, err := tracker.MonthlyUsage(r.Context(), client, now)
+			switch {
+			case r.Context().Err() != nil:
+				s.writeServiceError(w, r, err, "cannot check monthly quota")
+				return
+			case err != nil:
+				// Fail open: quotas are enforced on a best-effort basis.
+				s.log.LogAttrs(r.Context(), slog.LevelError, "cannot check monthly quota", slog.Any("error", err))
+			case lookups >= *key.MonthlyQuota:
+				writeError(w, http.StatusTooManyRequests, "monthly quota exceeded")
+				return
+			}
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r)
+		// Don't charge clients for failures on our side.
+		if sw.status < http.StatusInternalServerError && r.Context().Err() == nil {
+			tracker.Record(client, now)
+		}
+	}
+}
+
+// usageHandler handles the request to /v1/usage for the usage of the client making the request.
+func (s *Server) usageHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		client = s.clientID(r)
+		now    = time.Now().UTC()
+	)
+	usage, err := s.options().Usage.Usage(r.Context(), client, now)
+	if err != nil {
+		s.writeServiceError(w, r, err, "internal server error getting usage")
+		return
+	}
+
+	resp := UsageResponse{
+		Client: client,
+		Month:  now.Format("2006-01"),
+		Days:   make([]DailyUsage, 0, len(usage)),
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil {
+		resp.MonthlyQuota = key.MonthlyQuota
+	}
+	for _, u := range usage {
+		resp.Lookups += u.Lookups
+		resp.Days = append(resp.Days, DailyUsage{
+			Day:     u.Day.Format(time.DateOnly),
+			Lookups: u.Lookups,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	enc.Encode(resp)
+}
+
+// statusWriter records the HTTP status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMeter(t *testing.T) {
+	t.Parallel()
+	quota := int64(2)
+	key := &vio.APIKey{ID: 7, Name: "batch", Scopes: []string{vio.ScopeLookup}, MonthlyQuota: &quota}
+
+	m := mock.NewMockDB(gomock.NewController(t))
+	m.EXPECT().GetAPIKey(gomock.Any(), gomock.Any()).Return(key, nil).AnyTimes()
+	m.EXPECT().GetUsage(gomock.Any(), "key:7", gomock.Any(), gomock.Any()).Return([]vio.Usage{}, nil)
+
+	s := NewServer("", vio.NewService(m), slog.Default(), Options{
+		Usage: vio.NewUsageTracker(m, slog.Default()),
+	})
+	h := s.authenticate(s.meter(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for i, want := range []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/lookup?ip=127.0.0.1", nil)
+		r.Header.Set("X-API-Key", "vio_batch")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("request %d: got status %d, want %d", i, w.Code, want)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/usage", nil)
+	r.Header.Set("X-API-Key", "vio_batch")
+	w := httptest.NewRecorder()
+	m.EXPECT().GetUsage(gomock.Any(), "key:7", gomock.Any(), gomock.Any()).Return(nil, nil)
+	s.authenticate(http.HandlerFunc(s.usageHandler)).ServeHTTP(w, r)
+	var got UsageResponse
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("cannot decode usage: %v", err)
+	}
+	if got.Client != "key:7" || got.Lookups != 2 || len(got.Days) != 1 || got.MonthlyQuota == nil || *got.MonthlyQuota != 2 {
+		t.Errorf("unexpected usage response: %+v", got)
+	}
+}
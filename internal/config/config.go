@@ -0,0 +1,574 @@
+// Package config loads the configuration of the vio commands.
+//
+// Settings are read with the following precedence, from highest to lowest:
+// command-line flags, environment variables, configuration file (YAML), and default values.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/henvic/vio/internal/logging"
+	"github.com/jackc/pgx/v5/tracelog"
+	"gopkg.in/yaml.v3"
+)
+
+// Program using the configuration, which determines the settings available as flags.
+type Program int
+
+const (
+	// Server program (cmd/server).
+	Server Program = iota
+
+	// Importer program (cmd/import).
+	Importer
+
+	// CLI program (cmd/vioctl).
+	CLI
+)
+
+// Config of the vio commands.
+type Config struct {
+	Log    logging.Config `yaml:"log"`
+	Server ServerConfig   `yaml:"server"`
+	Import ImportConfig   `yaml:"import"`
+
+	// File the configuration was loaded from, if any.
+	File string `yaml:"-"`
+}
+
+// ServerConfig for the API server.
+type ServerConfig struct {
+	// HTTP address to listen for incoming requests on.
+	HTTP string `yaml:"http"`
+
+	// AdminAddr to listen for admin requests on. Disabled if empty.
+	AdminAddr string `yaml:"admin_addr"`
+
+	// ShutdownTimeout for requests to finish after a shutdown signal.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	// ReadHeaderTimeout for reading request headers.
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+
+	// RequestTimeout for handling a request. Disabled if zero.
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+
+	// DBStatementTimeout aborts database queries taking longer than it. Disabled if zero.
+	DBStatementTimeout time.Duration `yaml:"db_statement_timeout"`
+
+	// CacheControl policy for geolocation responses.
+	CacheControl string `yaml:"cache_control"`
+
+	// RequireAuth rejects requests without a valid API key.
+	RequireAuth bool `yaml:"require_auth"`
+
+	// UsageFlushInterval for saving usage counters to the database. Usage accounting is disabled if zero.
+	UsageFlushInterval time.Duration `yaml:"usage_flush_interval"`
+
+	// TrustedProxies IP addresses or CIDR ranges.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	RateLimit   RateLimitConfig   `yaml:"rate_limit"`
+	CORS        CORSConfig        `yaml:"cors"`
+	TLS         TLSConfig         `yaml:"tls"`
+	Compression CompressionConfig `yaml:"compression"`
+}
+
+// RateLimitConfig for clients of the API.
+type RateLimitConfig struct {
+	// Rate of requests per second. Rate limiting is disabled if zero.
+	Rate float64 `yaml:"rate"`
+
+	// Burst of requests allowed.
+	Burst int `yaml:"burst"`
+}
+
+// CORSConfig for browser clients.
+type CORSConfig struct {
+	// Origins allowed. CORS is disabled if empty.
+	Origins []string      `yaml:"origins"`
+	Methods []string      `yaml:"methods"`
+	Headers []string      `yaml:"headers"`
+	MaxAge  time.Duration `yaml:"max_age"`
+}
+
+// TLSConfig for serving HTTPS.
+type TLSConfig struct {
+	Cert           string        `yaml:"cert"`
+	Key            string        `yaml:"key"`
+	ClientCA       string        `yaml:"client_ca"`
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// CompressionConfig for responses.
+type CompressionConfig struct {
+	// Enabled compression of responses, negotiated with the Accept-Encoding header.
+	Enabled bool `yaml:"enabled"`
+
+	// MinSize of the response body in bytes for it to be compressed.
+	MinSize int `yaml:"min_size"`
+}
+
+// ImportConfig for the importer.
+type ImportConfig struct {
+	// Files to import, in order: file names, glob patterns, or - for the standard input.
+	Files FileList `yaml:"file"`
+
+	// BatchSize is the number of records sent to the database in a single batch.
+	BatchSize int `yaml:"batch_size"`
+
+	// Workers is the number of batches sent to the database concurrently.
+	Workers int `yaml:"workers"`
+
+	// Mode of the import: upsert keeps the geolocations missing from the file, and sync prunes them.
+	Mode string `yaml:"mode"`
+
+	// MaxPruneRatio is the maximum fraction of the geolocations a sync import may prune (0 disables the limit).
+	MaxPruneRatio float64 `yaml:"max_prune_ratio"`
+
+	// DryRun parses the file and compares it with the current geolocations without writing to the database.
+	DryRun bool `yaml:"dry_run"`
+
+	// Rejects is the file for writing the discarded records to (disabled if empty).
+	Rejects string `yaml:"rejects"`
+
+	// MaxDiscardRatio is the maximum fraction of the records that may be discarded (0 disables it).
+	MaxDiscardRatio float64 `yaml:"max_discard_ratio"`
+
+	// MaxChangeRatio is the maximum fraction of the current geolocations an import may change (0 disables it).
+	MaxChangeRatio float64 `yaml:"max_change_ratio"`
+
+	// MinRecords is the minimum number of records that must be accepted (0 disables it).
+	MinRecords int `yaml:"min_records"`
+
+	// MaxCountryDelta is the maximum fraction by which the number of geolocations of a country may change (0 disables it).
+	MaxCountryDelta float64 `yaml:"max_country_delta"`
+
+	// Resume the last import of the file if it failed, continuing from its checkpoint.
+	Resume bool `yaml:"resume"`
+
+	// MaxAttempts to send a batch to the database, or to apply the import, when it fails with a transient error.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// RetryBackoff before retrying a batch the first time, doubled on each retry up to RetryMaxBackoff.
+	RetryBackoff time.Duration `yaml:"retry_backoff"`
+
+	// RetryMaxBackoff between retries of a batch.
+	RetryMaxBackoff time.Duration `yaml:"retry_max_backoff"`
+}
+
+// FileList is a list of files, which might be set to a single file on the configuration file.
+type FileList []string
+
+// UnmarshalYAML accepts a single file or a list of files.
+func (l *FileList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = FileList{n.Value}
+		return nil
+	}
+	return n.Decode((*[]string)(l))
+}
+
+// Default configuration.
+func Default() Config {
+	return Config{
+		Log: logging.Config{
+			Format:   "text",
+			Level:    "info",
+			PgxLevel: "error",
+			Output:   "stderr",
+		},
+		Server: ServerConfig{
+			HTTP:              "localhost:8080",
+			ShutdownTimeout:   3 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			CacheControl:      "max-age=3600, public",
+			RateLimit: RateLimitConfig{
+				Burst: 20,
+			},
+			CORS: CORSConfig{
+				Methods: []string{"GET", "HEAD"},
+				Headers: []string{"Authorization", "X-API-Key"},
+				MaxAge:  10 * time.Minute,
+			},
+			TLS: TLSConfig{
+				ReloadInterval: time.Minute,
+			},
+			Compression: CompressionConfig{
+				MinSize: 1024,
+			},
+		},
+		Import: ImportConfig{
+			Files:           FileList{"data_dump.csv"},
+			BatchSize:       25000,
+			Workers:         1,
+			Mode:            "upsert",
+			MaxPruneRatio:   0.1,
+			MaxAttempts:     1,
+			RetryBackoff:    100 * time.Millisecond,
+			RetryMaxBackoff: 10 * time.Second,
+		},
+	}
+}
+
+// binder registers flags bound to the configuration, and their environment variables.
+type binder struct {
+	fs  *flag.FlagSet
+	env map[string]string
+}
+
+func (b binder) string(p *string, name, env, usage string) {
+	b.fs.StringVar(p, name, *p, usage)
+	b.env[name] = env
+}
+
+func (b binder) bool(p *bool, name, env, usage string) {
+	b.fs.BoolVar(p, name, *p, usage)
+	b.env[name] = env
+}
+
+func (b binder) int(p *int, name, env, usage string) {
+	b.fs.IntVar(p, name, *p, usage)
+	b.env[name] = env
+}
+
+func (b binder) float64(p *float64, name, env, usage string) {
+	b.fs.Float64Var(p, name, *p, usage)
+	b.env[name] = env
+}
+
+func (b binder) duration(p *time.Duration, name, env, usage string) {
+	b.fs.DurationVar(p, name, *p, usage)
+	b.env[name] = env
+}
+
+func (b binder) list(p *[]string, name, env, usage string) {
+	b.fs.Var((*listValue)(p), name, usage)
+	b.env[name] = env
+}
+
+// listValue is a comma-separated list flag.
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+func (b binder) repeated(p *[]string, name, env, usage string) {
+	b.fs.Var(&repeatedValue{p: p}, name, usage)
+	b.env[name] = env
+}
+
+// repeatedValue is a list flag that is repeated to add values.
+// The first value set replaces the values from the previous source of settings.
+type repeatedValue struct {
+	p     *[]string
+	added bool
+}
+
+func (r *repeatedValue) String() string {
+	if r == nil || r.p == nil {
+		return ""
+	}
+	return strings.Join(*r.p, ",")
+}
+
+func (r *repeatedValue) Set(s string) error {
+	if !r.added {
+		*r.p, r.added = nil, true
+	}
+	*r.p = append(*r.p, s)
+	return nil
+}
+
+// bind the settings of the program to flags.
+func (c *Config) bind(b binder, p Program) {
+	b.string(&c.Log.Format, "log-format", "LOG_FORMAT", "Log format (text or json)")
+	b.string(&c.Log.Level, "log-level", "LOG_LEVEL", "Minimum log level (debug, info, warn, or error)")
+	b.string(&c.Log.PgxLevel, "pgx-log-level", "PGX_LOG_LEVEL", "pgx trace log level (trace, debug, info, warn, error, or none)")
+	b.string(&c.Log.Output, "log-output", "LOG_OUTPUT", "Log output (stderr, stdout, or a file path)")
+
+	switch p {
+	case Server:
+		s := &c.Server
+		b.string(&s.HTTP, "http", "VIO_HTTP", "HTTP service address to listen for incoming requests on (host:port, unix:/path, or systemd[:name])")
+		b.string(&s.AdminAddr, "admin-addr", "VIO_ADMIN_ADDR", "Admin HTTP service address for pprof, runtime information, and configuration (disabled if empty)")
+		b.duration(&s.ShutdownTimeout, "shutdown-timeout", "VIO_SHUTDOWN_TIMEOUT", "Grace period for requests to finish after a shutdown signal")
+		b.duration(&s.ReadHeaderTimeout, "read-header-timeout", "VIO_READ_HEADER_TIMEOUT", "Timeout for reading request headers")
+		b.duration(&s.RequestTimeout, "request-timeout", "VIO_REQUEST_TIMEOUT", "Timeout for handling a request (0 disables it)")
+		b.duration(&s.DBStatementTimeout, "db-statement-timeout", "VIO_DB_STATEMENT_TIMEOUT", "Timeout for database queries (0 disables it)")
+		b.string(&s.CacheControl, "cache-control", "VIO_CACHE_CONTROL", "Cache-Control policy for geolocation responses")
+		b.bool(&s.RequireAuth, "require-auth", "VIO_REQUIRE_AUTH", "Require an API key to access the API")
+		b.duration(&s.UsageFlushInterval, "usage-flush-interval", "VIO_USAGE_FLUSH_INTERVAL", "Interval for saving usage counters to the database (0 disables usage accounting)")
+		b.list(&s.TrustedProxies, "trusted-proxies", "VIO_TRUSTED_PROXIES", "Comma-separated list of trusted proxy IP addresses or CIDR ranges")
+		b.float64(&s.RateLimit.Rate, "rate-limit", "VIO_RATE_LIMIT", "Requests per second allowed for each client IP address and API key (0 disables rate limiting)")
+		b.int(&s.RateLimit.Burst, "rate-limit-burst", "VIO_RATE_LIMIT_BURST", "Maximum burst of requests allowed for each client")
+		b.list(&s.CORS.Origins, "cors-origins", "VIO_CORS_ORIGINS", "Comma-separated list of origins allowed to make cross-origin requests (* for any)")
+		b.list(&s.CORS.Methods, "cors-methods", "VIO_CORS_METHODS", "Comma-separated list of methods allowed for cross-origin requests")
+		b.list(&s.CORS.Headers, "cors-headers", "VIO_CORS_HEADERS", "Comma-separated list of headers allowed for cross-origin requests")
+		b.duration(&s.CORS.MaxAge, "cors-max-age", "VIO_CORS_MAX_AGE", "Maximum time to cache the result of a CORS preflight request")
+		b.string(&s.TLS.Cert, "tls-cert", "VIO_TLS_CERT", "TLS certificate file for serving HTTPS")
+		b.string(&s.TLS.Key, "tls-key", "VIO_TLS_KEY", "TLS private key file for serving HTTPS")
+		b.string(&s.TLS.ClientCA, "tls-client-ca", "VIO_TLS_CLIENT_CA", "CA bundle file for requiring and verifying client certificates (mutual TLS)")
+		b.duration(&s.TLS.ReloadInterval, "tls-reload-interval", "VIO_TLS_RELOAD_INTERVAL", "Interval for checking TLS files for changes")
+		b.bool(&s.Compression.Enabled, "compression", "VIO_COMPRESSION", "Compress responses when accepted by the client")
+		b.int(&s.Compression.MinSize, "compression-min-size", "VIO_COMPRESSION_MIN_SIZE", "Minimum response size in bytes for compressing it")
+	case Importer:
+		i := &c.Import
+		b.repeated((*[]string)(&i.Files), "file", "VIO_IMPORT_FILE", "Data dump file, glob pattern, or - for the standard input. Repeat it to import multiple files")
+		b.int(&i.BatchSize, "batch-size", "VIO_IMPORT_BATCH_SIZE", "Batch size for the importer")
+		b.int(&i.Workers, "workers", "VIO_IMPORT_WORKERS", "Number of batches sent to the database concurrently")
+		b.string(&i.Mode, "mode", "VIO_IMPORT_MODE", "Import mode: upsert, or sync for pruning the geolocations missing from the file")
+		b.float64(&i.MaxPruneRatio, "max-prune-ratio", "VIO_IMPORT_MAX_PRUNE_RATIO", "Maximum fraction of the geolocations a sync import may prune (0 disables the limit)")
+		b.bool(&i.DryRun, "dry-run", "VIO_IMPORT_DRY_RUN", "Parse the file and compare it with the current geolocations without writing to the database")
+		b.string(&i.Rejects, "rejects", "VIO_IMPORT_REJECTS", "File for writing the discarded records to as CSV (disabled if empty)")
+		b.float64(&i.MaxDiscardRatio, "max-discard-ratio", "VIO_IMPORT_MAX_DISCARD_RATIO", "Maximum fraction of the records that may be discarded (0 disables it)")
+		b.float64(&i.MaxChangeRatio, "max-change-ratio", "VIO_IMPORT_MAX_CHANGE_RATIO", "Maximum fraction of the current geolocations an import may change (0 disables it)")
+		b.int(&i.MinRecords, "min-records", "VIO_IMPORT_MIN_RECORDS", "Minimum number of records that must be accepted (0 disables it)")
+		b.float64(&i.MaxCountryDelta, "max-country-delta", "VIO_IMPORT_MAX_COUNTRY_DELTA", "Maximum fraction by which the number of geolocations of a country may change (0 disables it)")
+		b.bool(&i.Resume, "resume", "VIO_IMPORT_RESUME", "Resume the last import of the file from its checkpoint if it failed")
+		b.int(&i.MaxAttempts, "max-attempts", "VIO_IMPORT_MAX_ATTEMPTS", "Maximum attempts to send a batch to the database, or to apply the import, when it fails with a transient error")
+		b.duration(&i.RetryBackoff, "retry-backoff", "VIO_IMPORT_RETRY_BACKOFF", "Backoff before retrying a batch, doubled on each retry")
+		b.duration(&i.RetryMaxBackoff, "retry-max-backoff", "VIO_IMPORT_RETRY_MAX_BACKOFF", "Maximum backoff between retries of a batch")
+	}
+}
+
+// Load the configuration of the program, registering its flags on fs and parsing args.
+//
+// The configuration file is set with the -config flag or the VIO_CONFIG environment variable.
+func Load(fs *flag.FlagSet, p Program, args []string) (*Config, error) {
+	c := Default()
+	b := binder{
+		fs:  fs,
+		env: map[string]string{},
+	}
+	c.bind(b, p)
+	file := fs.String("config", "", "Configuration file (YAML). Can also be set with the VIO_CONFIG environment variable")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Flags were parsed first to find the configuration file,
+	// but they must override the values from the file and environment, so they are parsed again.
+	// Each source of settings replaces the values of repeated flags.
+	reset := func() {
+		fs.VisitAll(func(f *flag.Flag) {
+			if r, ok := f.Value.(*repeatedValue); ok {
+				r.added = false
+			}
+		})
+	}
+
+	c = Default()
+	if *file == "" {
+		*file = os.Getenv("VIO_CONFIG")
+	}
+	if *file != "" {
+		if err := c.loadFile(*file); err != nil {
+			return nil, err
+		}
+		c.File = *file
+	}
+	reset()
+	for name, env := range b.env {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return nil, fmt.Errorf("invalid value %q for environment variable %s: %w", v, env, err)
+		}
+	}
+	reset()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := c.Validate(p); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// loadFile loads the YAML configuration file on top of the current configuration.
+func (c *Config) loadFile(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("cannot read configuration file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	// An empty file is valid.
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return fmt.Errorf("cannot parse configuration file %s: %w", name, err)
+	}
+	return nil
+}
+
+// Validate the configuration of the program.
+func (c *Config) Validate(p Program) error {
+	var errs []error
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("invalid log level: %q", c.Log.Level))
+	}
+	if _, err := tracelog.LogLevelFromString(c.Log.PgxLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid pgx log level: %q", c.Log.PgxLevel))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("invalid log format: %q", c.Log.Format))
+	}
+
+	switch p {
+	case Server:
+		s := c.Server
+		if s.HTTP == "" {
+			errs = append(errs, errors.New("missing HTTP address"))
+		}
+		if s.ShutdownTimeout <= 0 {
+			errs = append(errs, errors.New("shutdown timeout must be positive"))
+		}
+		if s.ReadHeaderTimeout <= 0 {
+			errs = append(errs, errors.New("read header timeout must be positive"))
+		}
+		if s.UsageFlushInterval < 0 {
+			errs = append(errs, errors.New("usage flush interval cannot be negative"))
+		}
+		if s.RateLimit.Rate < 0 {
+			errs = append(errs, errors.New("rate limit cannot be negative"))
+		}
+		if s.RateLimit.Rate > 0 && s.RateLimit.Burst < 1 {
+			errs = append(errs, errors.New("rate limit burst must be at least 1"))
+		}
+		if _, err := s.ParseTrustedProxies(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid trusted proxies: %w", err))
+		}
+		if s.Compression.MinSize < 0 {
+			errs = append(errs, errors.New("compression minimum size cannot be negative"))
+		}
+		switch {
+		case (s.TLS.Cert == "") != (s.TLS.Key == ""):
+			errs = append(errs, errors.New("both TLS certificate and key are required for serving HTTPS"))
+		case s.TLS.ClientCA != "" && s.TLS.Cert == "":
+			errs = append(errs, errors.New("TLS client CA requires a TLS certificate and key"))
+		}
+	case Importer:
+		if len(c.Import.Files) == 0 {
+			errs = append(errs, errors.New("missing file to import"))
+		}
+		if stdin := slices.Index(c.Import.Files, "-"); stdin != -1 && slices.Index(c.Import.Files[stdin+1:], "-") != -1 {
+			errs = append(errs, errors.New("standard input can only be imported once"))
+		}
+		if c.Import.BatchSize < 1 {
+			errs = append(errs, errors.New("batch size must be at least 1"))
+		}
+		if c.Import.Workers < 1 {
+			errs = append(errs, errors.New("number of workers must be at least 1"))
+		}
+		if c.Import.Mode != "upsert" && c.Import.Mode != "sync" {
+			errs = append(errs, fmt.Errorf("invalid import mode %q: must be upsert or sync", c.Import.Mode))
+		}
+		if c.Import.MaxPruneRatio < 0 || c.Import.MaxPruneRatio > 1 {
+			errs = append(errs, errors.New("maximum prune ratio must be between 0 and 1"))
+		}
+		for _, v := range []struct {
+			name  string
+			ratio float64
+		}{
+			{"maximum discard ratio", c.Import.MaxDiscardRatio},
+			{"maximum change ratio", c.Import.MaxChangeRatio},
+			{"maximum country delta", c.Import.MaxCountryDelta},
+		} {
+			if v.ratio < 0 || v.ratio > 1 {
+				errs = append(errs, fmt.Errorf("%s must be between 0 and 1", v.name))
+			}
+		}
+		if c.Import.MinRecords < 0 {
+			errs = append(errs, errors.New("minimum number of records cannot be negative"))
+		}
+		if c.Import.MaxAttempts < 1 {
+			errs = append(errs, errors.New("maximum attempts must be at least 1"))
+		}
+		if c.Import.RetryBackoff <= 0 || c.Import.RetryMaxBackoff < c.Import.RetryBackoff {
+			errs = append(errs, errors.New("retry backoff must be positive and not above the maximum retry backoff"))
+		}
+		if c.Import.Resume && c.Import.DryRun {
+			errs = append(errs, errors.New("cannot resume a dry run"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ParseTrustedProxies parses the IP addresses or CIDR ranges of the trusted proxies.
+func (s ServerConfig) ParseTrustedProxies() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, v := range s.TrustedProxies {
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// Reloadable settings of the server, which can be changed without restarting it.
+// Changes to other settings are ignored when reloading the configuration.
+func (s ServerConfig) Reloadable(next ServerConfig) ServerConfig {
+	r := s
+	r.CacheControl = next.CacheControl
+	r.RequestTimeout = next.RequestTimeout
+	r.TrustedProxies = next.TrustedProxies
+	r.RateLimit = next.RateLimit
+	r.CORS = next.CORS
+	return r
+}
+
+// Dump the configuration as a map for diagnostics.
+// It doesn't contain secrets: database credentials are read from the libpq environment variables.
+func (c *Config) Dump() (map[string]any, error) {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if c.File != "" {
+		m["file"] = c.File
+	}
+	return m, nil
+}
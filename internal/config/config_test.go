@@ -0,0 +1,306 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func load(t *testing.T, p Program, args ...string) (*Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, p, args)
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "vio.yaml")
+	if err := os.WriteFile(name, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("VIO_CONFIG", "")
+	c, err := load(t, Server)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Default()
+	if diff := cmp.Diff(&want, c); diff != "" {
+		t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	name := writeFile(t, `
+log:
+  level: warn
+server:
+  http: localhost:9000
+  cache_control: no-cache
+  trusted_proxies: [10.0.0.0/8]
+  rate_limit:
+    rate: 5
+    burst: 10
+`)
+	t.Setenv("VIO_CONFIG", name)
+	t.Setenv("VIO_HTTP", "localhost:9001")
+	t.Setenv("VIO_RATE_LIMIT_BURST", "15")
+	t.Setenv("LOG_LEVEL", "error")
+
+	c, err := load(t, Server, "-http=localhost:9002", "-log-level=debug")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Default()
+	want.File = name
+	want.Log.Level = "debug"
+	want.Server.HTTP = "localhost:9002"
+	want.Server.CacheControl = "no-cache"
+	want.Server.TrustedProxies = []string{"10.0.0.0/8"}
+	want.Server.RateLimit = RateLimitConfig{Rate: 5, Burst: 15}
+	if diff := cmp.Diff(&want, c); diff != "" {
+		t.Errorf("Load() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestLoadConfigFlag(t *testing.T) {
+	t.Setenv("VIO_CONFIG", writeFile(t, "import: {batch_size: 10}"))
+	name := writeFile(t, "import: {file: dump.csv, batch_size: 500}")
+	c, err := load(t, Importer, "-config", name)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Default().Import
+	want.Files = FileList{"dump.csv"}
+	want.BatchSize = 500
+	if diff := cmp.Diff(want, c.Import); diff != "" {
+		t.Errorf("Load() import config mismatch (-want +got):\n%s", diff)
+	}
+	if c.File != name {
+		t.Errorf("Load() file = %q, want %q", c.File, name)
+	}
+}
+
+func TestLoadRepeatedFlag(t *testing.T) {
+	t.Setenv("VIO_CONFIG", writeFile(t, "import: {file: [a.csv, b.csv]}"))
+	c, err := load(t, Importer)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := (FileList{"a.csv", "b.csv"}); !slices.Equal(c.Import.Files, want) {
+		t.Errorf("Load() files = %q, want %q", c.Import.Files, want)
+	}
+
+	t.Setenv("VIO_IMPORT_FILE", "env.csv")
+	if c, err = load(t, Importer); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := (FileList{"env.csv"}); !slices.Equal(c.Import.Files, want) {
+		t.Errorf("Load() files = %q, want %q", c.Import.Files, want)
+	}
+
+	if c, err = load(t, Importer, "-file", "-", "-file", "shards/dump-*.csv.gz"); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := (FileList{"-", "shards/dump-*.csv.gz"}); !slices.Equal(c.Import.Files, want) {
+		t.Errorf("Load() files = %q, want %q", c.Import.Files, want)
+	}
+}
+
+func TestLoadEmptyFile(t *testing.T) {
+	t.Setenv("VIO_CONFIG", writeFile(t, ""))
+	if _, err := load(t, Server); err != nil {
+		t.Errorf("Load() error = %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		program Program
+		file    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown_field",
+			program: Server,
+			file:    "server: {htp: localhost:80}",
+			wantErr: "field htp not found",
+		},
+		{
+			name:    "invalid_env",
+			program: Server,
+			env:     map[string]string{"VIO_REQUIRE_AUTH": "maybe"},
+			wantErr: `invalid value "maybe" for environment variable VIO_REQUIRE_AUTH`,
+		},
+		{
+			name:    "unknown_flag",
+			program: CLI,
+			args:    []string{"-http=localhost:80"},
+			wantErr: "flag provided but not defined: -http",
+		},
+		{
+			name:    "invalid_log_level",
+			program: CLI,
+			args:    []string{"-log-level=loud"},
+			wantErr: `invalid log level: "loud"`,
+		},
+		{
+			name:    "burst",
+			program: Server,
+			args:    []string{"-rate-limit=1", "-rate-limit-burst=0"},
+			wantErr: "rate limit burst must be at least 1",
+		},
+		{
+			name:    "trusted_proxies",
+			program: Server,
+			args:    []string{"-trusted-proxies=10.0.0.0/33"},
+			wantErr: "invalid trusted proxies",
+		},
+		{
+			name:    "tls",
+			program: Server,
+			args:    []string{"-tls-cert=cert.pem"},
+			wantErr: "both TLS certificate and key are required",
+		},
+		{
+			name:    "batch_size",
+			program: Importer,
+			env:     map[string]string{"VIO_IMPORT_BATCH_SIZE": "0"},
+			wantErr: "batch size must be at least 1",
+		},
+		{
+			name:    "workers",
+			program: Importer,
+			args:    []string{"-workers=0"},
+			wantErr: "number of workers must be at least 1",
+		},
+		{
+			name:    "import_mode",
+			program: Importer,
+			args:    []string{"-mode=replace"},
+			wantErr: `invalid import mode "replace"`,
+		},
+		{
+			name:    "max_prune_ratio",
+			program: Importer,
+			args:    []string{"-mode=sync", "-max-prune-ratio=1.5"},
+			wantErr: "maximum prune ratio must be between 0 and 1",
+		},
+		{
+			name:    "max_discard_ratio",
+			program: Importer,
+			env:     map[string]string{"VIO_IMPORT_MAX_DISCARD_RATIO": "-0.1"},
+			wantErr: "maximum discard ratio must be between 0 and 1",
+		},
+		{
+			name:    "max_attempts",
+			program: Importer,
+			args:    []string{"-max-attempts=0"},
+			wantErr: "maximum attempts must be at least 1",
+		},
+		{
+			name:    "retry_backoff",
+			program: Importer,
+			env:     map[string]string{"VIO_IMPORT_RETRY_BACKOFF": "1m"},
+			wantErr: "retry backoff must be positive and not above the maximum retry backoff",
+		},
+		{
+			name:    "stdin_twice",
+			program: Importer,
+			args:    []string{"-file=-", "-file=a.csv", "-file=-"},
+			wantErr: "standard input can only be imported once",
+		},
+		{
+			name:    "resume_dry_run",
+			program: Importer,
+			args:    []string{"-resume", "-dry-run"},
+			wantErr: "cannot resume a dry run",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file string
+			if tt.file != "" {
+				file = writeFile(t, tt.file)
+			}
+			t.Setenv("VIO_CONFIG", file)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := load(t, tt.program, tt.args...)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	t.Parallel()
+	s := ServerConfig{TrustedProxies: []string{"::ffff:10.0.0.1", "192.168.1.7/24"}}
+	got, err := s.ParseTrustedProxies()
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.1/32"),
+		netip.MustParsePrefix("192.168.1.0/24"),
+	}
+	if !cmp.Equal(want, got, cmp.Comparer(func(a, b netip.Prefix) bool { return a == b })) {
+		t.Errorf("ParseTrustedProxies() = %v, want %v", got, want)
+	}
+}
+
+func TestReloadable(t *testing.T) {
+	t.Parallel()
+	cur := Default().Server
+	next := cur
+	next.HTTP = "localhost:9000"
+	next.ShutdownTimeout = time.Minute
+	next.CacheControl = "no-store"
+	next.TrustedProxies = []string{"10.0.0.1"}
+	next.RateLimit = RateLimitConfig{Rate: 1, Burst: 1}
+	next.CORS.Origins = []string{"*"}
+
+	got := cur.Reloadable(next)
+	want := cur
+	want.CacheControl = "no-store"
+	want.TrustedProxies = []string{"10.0.0.1"}
+	want.RateLimit = RateLimitConfig{Rate: 1, Burst: 1}
+	want.CORS.Origins = []string{"*"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Reloadable() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+	c := Default()
+	c.File = "/etc/vio.yaml"
+	got, err := c.Dump()
+	if err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if got["file"] != "/etc/vio.yaml" {
+		t.Errorf("Dump() file = %v", got["file"])
+	}
+	server, ok := got["server"].(map[string]any)
+	if !ok || server["http"] != "localhost:8080" {
+		t.Errorf("Dump() server = %v", got["server"])
+	}
+}
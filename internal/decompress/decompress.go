@@ -0,0 +1,184 @@
+// Package decompress reads compressed data dumps, detecting the compression by its magic bytes.
+package decompress
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Formats of the input.
+const (
+	None  = ""
+	Gzip  = "gzip"
+	Bzip2 = "bzip2"
+	Zstd  = "zstd"
+	Zip   = "zip"
+)
+
+// ErrZipStream is returned when reading a zip archive from a stream, as it requires random access.
+var ErrZipStream = errors.New("zip archives can only be read from files")
+
+var magic = []struct {
+	format string
+	bytes  []byte
+}{
+	{Gzip, []byte{0x1f, 0x8b}},
+	{Bzip2, []byte("BZh")},
+	{Zstd, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{Zip, []byte("PK\x03\x04")},
+	{Zip, []byte("PK\x05\x06")}, // Empty archive.
+}
+
+// Detect the format of the input from its first bytes.
+func Detect(header []byte) string {
+	for _, m := range magic {
+		if bytes.HasPrefix(header, m.bytes) {
+			return m.format
+		}
+	}
+	return None
+}
+
+// NewReader decompresses gzip, bzip2, or zstd input. Uncompressed input is read as it is.
+func NewReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch format := Detect(header); format {
+	case Gzip:
+		return gzip.NewReader(br)
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(br)), nil
+	case Zstd:
+		d, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	case Zip:
+		return nil, ErrZipStream
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// Open a data dump file, decompressing it if needed.
+// The CSV members of a zip archive are read in the order they were archived.
+func Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var r io.ReadCloser
+	if Detect(header[:n]) == Zip {
+		r, err = openZip(f)
+	} else {
+		r, err = NewReader(f)
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot decompress %s: %w", name, err)
+	}
+	return &fileReader{ReadCloser: r, f: f}, nil
+}
+
+// fileReader closes the file after the decompressor.
+type fileReader struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r *fileReader) Close() error {
+	return errors.Join(r.ReadCloser.Close(), r.f.Close())
+}
+
+// openZip reads the CSV members of the zip archive, one after the other.
+func openZip(f *os.File) (io.ReadCloser, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	archive, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		return nil, err
+	}
+	var members []*zip.File
+	for _, m := range archive.File {
+		if !m.FileInfo().IsDir() && strings.EqualFold(path.Ext(m.Name), ".csv") {
+			members = append(members, m)
+		}
+	}
+	if len(members) == 0 {
+		return nil, errors.New("zip archive has no CSV files")
+	}
+	return &zipReader{members: members}, nil
+}
+
+// zipReader reads the members of a zip archive, separated by a line break in case a member doesn't end with one.
+type zipReader struct {
+	members []*zip.File
+	current io.ReadCloser
+	newline bool
+}
+
+func (z *zipReader) Read(p []byte) (int, error) {
+	for {
+		if z.newline {
+			if len(p) == 0 {
+				return 0, nil
+			}
+			z.newline = false
+			p[0] = '\n'
+			return 1, nil
+		}
+		if z.current == nil {
+			if len(z.members) == 0 {
+				return 0, io.EOF
+			}
+			r, err := z.members[0].Open()
+			if err != nil {
+				return 0, fmt.Errorf("cannot open %s: %w", z.members[0].Name, err)
+			}
+			z.current, z.members = r, z.members[1:]
+		}
+		n, err := z.current.Read(p)
+		if err == io.EOF {
+			err = z.current.Close()
+			z.current, z.newline = nil, len(z.members) > 0
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+func (z *zipReader) Close() error {
+	if z.current == nil {
+		return nil
+	}
+	return z.current.Close()
+}
@@ -0,0 +1,98 @@
+package decompress
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const data = "192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0\n192.0.2.2,NL,Netherlands,Rotterdam,51.92,4.48,0\n"
+
+// bzip2 compressed data, as the standard library has no bzip2 compressor.
+// Generated with: printf '192.0.2.1,NL\n' | bzip2 | xxd -i
+var bzip2Data = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xc2, 0x30,
+	0x61, 0x1e, 0x00, 0x00, 0x03, 0xdc, 0x00, 0x00, 0x10, 0x00, 0x05, 0x70,
+	0x20, 0x00, 0x05, 0x20, 0x00, 0x31, 0x06, 0x4c, 0x40, 0xd0, 0x30, 0x8c,
+	0xa0, 0x90, 0x2f, 0x0c, 0x9e, 0x2e, 0xe4, 0x8a, 0x70, 0xa1, 0x21, 0x84,
+	0x60, 0xc2, 0x3c,
+}
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(data))
+	gw.Close()
+
+	var zs bytes.Buffer
+	zw, err := zstd.NewWriter(&zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write([]byte(data))
+	zw.Close()
+
+	var archive bytes.Buffer
+	aw := zip.NewWriter(&archive)
+	for _, m := range []struct{ name, content string }{
+		{"a.csv", "192.0.2.1,NL,Netherlands,Amsterdam,52.37,4.89,0"}, // No line break at the end.
+		{"README.txt", "not a data dump"},
+		{"b.CSV", "192.0.2.2,NL,Netherlands,Rotterdam,51.92,4.48,0\n"},
+	} {
+		w, err := aw.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(m.content))
+	}
+	aw.Close()
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"dump.csv", []byte(data), data},
+		{"dump.csv.gz", gz.Bytes(), data},
+		{"dump.csv.bz2", bzip2Data, "192.0.2.1,NL\n"},
+		{"dump.csv.zst", zs.Bytes(), data},
+		{"dump.zip", archive.Bytes(), data},
+		{"empty.csv", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			name := filepath.Join(t.TempDir(), tt.name)
+			if err := os.WriteFile(name, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			r, err := Open(name)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("cannot read: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Open() read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewReaderZip(t *testing.T) {
+	t.Parallel()
+	if _, err := NewReader(bytes.NewReader([]byte("PK\x03\x04"))); !errors.Is(err, ErrZipStream) {
+		t.Errorf("NewReader() error = %v, want %v", err, ErrZipStream)
+	}
+}
@@ -0,0 +1,111 @@
+// Package logging sets up the structured logger shared by the vio commands.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// Config for the logger.
+type Config struct {
+	// Format of the log output: text or json.
+	Format string `yaml:"format"`
+
+	// Level is the minimum log level: debug, info, warn, or error.
+	Level string `yaml:"level"`
+
+	// PgxLevel is the pgx trace log level: trace, debug, info, warn, error, or none.
+	PgxLevel string `yaml:"pgx_level"`
+
+	// Output is where logs are written to: stderr, stdout, or a file path.
+	Output string `yaml:"output"`
+}
+
+// New creates a logger from the configuration.
+// The minimum log level is set on level, which can be used to change it later.
+// The returned io.Closer must be called to release the log output once the logger is no longer used.
+func New(c Config, level *slog.LevelVar) (*slog.Logger, io.Closer, error) {
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return nil, nil, fmt.Errorf("invalid log level: %w", err)
+	}
+
+	var (
+		w      io.Writer
+		closer io.Closer = nopCloser{}
+	)
+	switch c.Output {
+	case "", "stderr":
+		w = os.Stderr
+	case "stdout":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(c.Output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("cannot open log output: %w", err)
+		}
+		w, closer = f, f
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch c.Format {
+	case "", "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("invalid log format: %q", c.Format)
+	}
+	return slog.New(h), closer, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// NewTracer creates a pgx tracer logging to log from the given pgx trace level onwards.
+func NewTracer(log *slog.Logger, level string) (*tracelog.TraceLog, error) {
+	ll, err := tracelog.LogLevelFromString(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pgx log level: %w", err)
+	}
+	return &tracelog.TraceLog{
+		Logger:   pgxLogger{log: log},
+		LogLevel: ll,
+	}, nil
+}
+
+// pgxLogger prints pgx logs to the slog logger.
+type pgxLogger struct {
+	log *slog.Logger
+}
+
+func (l pgxLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	attrs := make([]slog.Attr, 0, len(data)+1)
+	attrs = append(attrs, slog.String("pgx_level", level.String()))
+	for k, v := range data {
+		attrs = append(attrs, slog.Any(k, v))
+	}
+	l.log.LogAttrs(ctx, slogLevel(level), msg, attrs...)
+}
+
+// slogLevel translates pgx log level to slog log level.
+func slogLevel(level tracelog.LogLevel) slog.Level {
+	switch level {
+	case tracelog.LogLevelTrace, tracelog.LogLevelDebug:
+		return slog.LevelDebug
+	case tracelog.LogLevelInfo:
+		return slog.LevelInfo
+	case tracelog.LogLevelWarn:
+		return slog.LevelWarn
+	default:
+		// If tracelog.LogLevelError, tracelog.LogLevelNone, or any other unknown level, use slog.LevelError.
+		return slog.LevelError
+	}
+}
@@ -0,0 +1,154 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			config: Config{},
+			// Level is mandatory.
+			wantErr: `invalid log level: slog: level string "": unknown name`,
+		},
+		{
+			name: "text",
+			config: Config{
+				Format: "text",
+				Level:  "info",
+				Output: "stderr",
+			},
+		},
+		{
+			name: "json_stdout",
+			config: Config{
+				Format: "json",
+				Level:  "debug",
+				Output: "stdout",
+			},
+		},
+		{
+			name: "bad_format",
+			config: Config{
+				Format: "xml",
+				Level:  "info",
+			},
+			wantErr: `invalid log format: "xml"`,
+		},
+		{
+			name: "bad_level",
+			config: Config{
+				Format: "text",
+				Level:  "verbose",
+			},
+			wantErr: `invalid log level: slog: level string "verbose": unknown name`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			log, closer, err := New(tt.config, new(slog.LevelVar))
+			if err == nil && tt.wantErr != "" || err != nil && tt.wantErr != err.Error() {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if log == nil {
+				t.Error("New() logger should not be nil")
+			}
+			if err := closer.Close(); err != nil {
+				t.Errorf("cannot close log output: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewJSONFile(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "vio.log")
+	level := new(slog.LevelVar)
+	log, closer, err := New(Config{
+		Format: "json",
+		Level:  "error",
+		Output: name,
+	}, level)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	log.Warn("filtered out")
+	level.Set(slog.LevelWarn)
+	log.Info("filtered out")
+	log.Warn("hello", slog.String("ip", "127.0.0.1"))
+	if err := closer.Close(); err != nil {
+		t.Fatalf("cannot close log output: %v", err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("log output is not a single JSON record: %v\n%s", err, b)
+	}
+	if got["msg"] != "hello" || got["level"] != "WARN" || got["ip"] != "127.0.0.1" {
+		t.Errorf("unexpected log record: %s", b)
+	}
+}
+
+func TestNewTracer(t *testing.T) {
+	t.Parallel()
+	tracer, err := NewTracer(slog.Default(), "warn")
+	if err != nil {
+		t.Fatalf("NewTracer() error = %v", err)
+	}
+	if tracer.LogLevel != tracelog.LogLevelWarn {
+		t.Errorf("NewTracer() log level = %v, want warn", tracer.LogLevel)
+	}
+	if _, err := NewTracer(slog.Default(), "loud"); err == nil {
+		t.Error("NewTracer() should fail with invalid level")
+	}
+}
+
+func TestPgxLogger(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "pgx.log")
+	log, closer, err := New(Config{
+		Format: "json",
+		Level:  "debug",
+		Output: name,
+	}, new(slog.LevelVar))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	pgxLogger{log: log}.Log(context.Background(), tracelog.LogLevelTrace, "Query", map[string]any{"sql": "SELECT 1"})
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("cannot decode log record: %v", err)
+	}
+	if got["level"] != "DEBUG" || got["pgx_level"] != "trace" || got["sql"] != "SELECT 1" {
+		t.Errorf("unexpected log record: %s", b)
+	}
+}
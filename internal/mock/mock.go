@@ -12,7 +12,9 @@ package mock
 import (
 	context "context"
 	net "net"
+	netip "net/netip"
 	reflect "reflect"
+	time "time"
 
 	vio "github.com/henvic/vio"
 	gomock "go.uber.org/mock/gomock"
@@ -41,6 +43,182 @@ func (m *MockDB) EXPECT() *MockDBMockRecorder {
 	return m.recorder
 }
 
+// AddUsage mocks base method.
+func (m *MockDB) AddUsage(arg0 context.Context, arg1 []vio.Usage) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddUsage", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddUsage indicates an expected call of AddUsage.
+func (mr *MockDBMockRecorder) AddUsage(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddUsage", reflect.TypeOf((*MockDB)(nil).AddUsage), arg0, arg1)
+}
+
+// CreateAPIKey mocks base method.
+func (m *MockDB) CreateAPIKey(arg0 context.Context, arg1 *vio.APIKey, arg2 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAPIKey", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateAPIKey indicates an expected call of CreateAPIKey.
+func (mr *MockDBMockRecorder) CreateAPIKey(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAPIKey", reflect.TypeOf((*MockDB)(nil).CreateAPIKey), arg0, arg1, arg2)
+}
+
+// CreateCorrection mocks base method.
+func (m *MockDB) CreateCorrection(arg0 context.Context, arg1 *vio.Correction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCorrection", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateCorrection indicates an expected call of CreateCorrection.
+func (mr *MockDBMockRecorder) CreateCorrection(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCorrection", reflect.TypeOf((*MockDB)(nil).CreateCorrection), arg0, arg1)
+}
+
+// DeleteLocations mocks base method.
+func (m *MockDB) DeleteLocations(arg0 context.Context, arg1 netip.Prefix) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLocations", arg0, arg1)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteLocations indicates an expected call of DeleteLocations.
+func (mr *MockDBMockRecorder) DeleteLocations(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLocations", reflect.TypeOf((*MockDB)(nil).DeleteLocations), arg0, arg1)
+}
+
+// DeleteOverride mocks base method.
+func (m *MockDB) DeleteOverride(arg0 context.Context, arg1 net.IP) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOverride", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOverride indicates an expected call of DeleteOverride.
+func (mr *MockDBMockRecorder) DeleteOverride(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOverride", reflect.TypeOf((*MockDB)(nil).DeleteOverride), arg0, arg1)
+}
+
+// GetAPIKey mocks base method.
+func (m *MockDB) GetAPIKey(arg0 context.Context, arg1 []byte) (*vio.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(*vio.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAPIKey indicates an expected call of GetAPIKey.
+func (mr *MockDBMockRecorder) GetAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAPIKey", reflect.TypeOf((*MockDB)(nil).GetAPIKey), arg0, arg1)
+}
+
+// GetHistory mocks base method.
+func (m *MockDB) GetHistory(arg0 context.Context, arg1 net.IP, arg2 int) ([]vio.GeolocationVersion, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]vio.GeolocationVersion)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockDBMockRecorder) GetHistory(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockDB)(nil).GetHistory), arg0, arg1, arg2)
+}
+
+// GetUsage mocks base method.
+func (m *MockDB) GetUsage(arg0 context.Context, arg1 string, arg2, arg3 time.Time) ([]vio.Usage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUsage", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]vio.Usage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUsage indicates an expected call of GetUsage.
+func (mr *MockDBMockRecorder) GetUsage(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUsage", reflect.TypeOf((*MockDB)(nil).GetUsage), arg0, arg1, arg2, arg3)
+}
+
+// ListAPIKeys mocks base method.
+func (m *MockDB) ListAPIKeys(arg0 context.Context) ([]vio.APIKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAPIKeys", arg0)
+	ret0, _ := ret[0].([]vio.APIKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAPIKeys indicates an expected call of ListAPIKeys.
+func (mr *MockDBMockRecorder) ListAPIKeys(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAPIKeys", reflect.TypeOf((*MockDB)(nil).ListAPIKeys), arg0)
+}
+
+// ListCorrections mocks base method.
+func (m *MockDB) ListCorrections(arg0 context.Context, arg1 string) ([]vio.Correction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCorrections", arg0, arg1)
+	ret0, _ := ret[0].([]vio.Correction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCorrections indicates an expected call of ListCorrections.
+func (mr *MockDBMockRecorder) ListCorrections(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCorrections", reflect.TypeOf((*MockDB)(nil).ListCorrections), arg0, arg1)
+}
+
+// ListImportRuns mocks base method.
+func (m *MockDB) ListImportRuns(arg0 context.Context) ([]vio.ImportRun, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListImportRuns", arg0)
+	ret0, _ := ret[0].([]vio.ImportRun)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListImportRuns indicates an expected call of ListImportRuns.
+func (mr *MockDBMockRecorder) ListImportRuns(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListImportRuns", reflect.TypeOf((*MockDB)(nil).ListImportRuns), arg0)
+}
+
+// ListOverrides mocks base method.
+func (m *MockDB) ListOverrides(arg0 context.Context) ([]vio.Override, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOverrides", arg0)
+	ret0, _ := ret[0].([]vio.Override)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOverrides indicates an expected call of ListOverrides.
+func (mr *MockDBMockRecorder) ListOverrides(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOverrides", reflect.TypeOf((*MockDB)(nil).ListOverrides), arg0)
+}
+
 // LookupLocation mocks base method.
 func (m *MockDB) LookupLocation(arg0 context.Context, arg1 net.IP) (*vio.Geolocation, error) {
 	m.ctrl.T.Helper()
@@ -55,3 +233,120 @@ func (mr *MockDBMockRecorder) LookupLocation(arg0, arg1 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupLocation", reflect.TypeOf((*MockDB)(nil).LookupLocation), arg0, arg1)
 }
+
+// LookupLocationAt mocks base method.
+func (m *MockDB) LookupLocationAt(arg0 context.Context, arg1 net.IP, arg2 time.Time) (*vio.Geolocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LookupLocationAt", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*vio.Geolocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LookupLocationAt indicates an expected call of LookupLocationAt.
+func (mr *MockDBMockRecorder) LookupLocationAt(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LookupLocationAt", reflect.TypeOf((*MockDB)(nil).LookupLocationAt), arg0, arg1, arg2)
+}
+
+// PutLocation mocks base method.
+func (m *MockDB) PutLocation(arg0 context.Context, arg1 *vio.Geolocation) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutLocation", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutLocation indicates an expected call of PutLocation.
+func (mr *MockDBMockRecorder) PutLocation(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutLocation", reflect.TypeOf((*MockDB)(nil).PutLocation), arg0, arg1)
+}
+
+// PutOverride mocks base method.
+func (m *MockDB) PutOverride(arg0 context.Context, arg1 *vio.Override) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutOverride", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PutOverride indicates an expected call of PutOverride.
+func (mr *MockDBMockRecorder) PutOverride(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutOverride", reflect.TypeOf((*MockDB)(nil).PutOverride), arg0, arg1)
+}
+
+// ReviewCorrection mocks base method.
+func (m *MockDB) ReviewCorrection(arg0 context.Context, arg1 int64, arg2, arg3 string) (*vio.Correction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReviewCorrection", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*vio.Correction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReviewCorrection indicates an expected call of ReviewCorrection.
+func (mr *MockDBMockRecorder) ReviewCorrection(arg0, arg1, arg2, arg3 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReviewCorrection", reflect.TypeOf((*MockDB)(nil).ReviewCorrection), arg0, arg1, arg2, arg3)
+}
+
+// RevokeAPIKey mocks base method.
+func (m *MockDB) RevokeAPIKey(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAPIKey", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAPIKey indicates an expected call of RevokeAPIKey.
+func (mr *MockDBMockRecorder) RevokeAPIKey(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAPIKey", reflect.TypeOf((*MockDB)(nil).RevokeAPIKey), arg0, arg1)
+}
+
+// RollbackImportRun mocks base method.
+func (m *MockDB) RollbackImportRun(arg0 context.Context, arg1 int64) (*vio.ImportRollback, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollbackImportRun", arg0, arg1)
+	ret0, _ := ret[0].(*vio.ImportRollback)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RollbackImportRun indicates an expected call of RollbackImportRun.
+func (mr *MockDBMockRecorder) RollbackImportRun(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackImportRun", reflect.TypeOf((*MockDB)(nil).RollbackImportRun), arg0, arg1)
+}
+
+// SetAPIKeyQuota mocks base method.
+func (m *MockDB) SetAPIKeyQuota(arg0 context.Context, arg1 int64, arg2 *int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAPIKeyQuota", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAPIKeyQuota indicates an expected call of SetAPIKeyQuota.
+func (mr *MockDBMockRecorder) SetAPIKeyQuota(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAPIKeyQuota", reflect.TypeOf((*MockDB)(nil).SetAPIKeyQuota), arg0, arg1, arg2)
+}
+
+// UpdateLocations mocks base method.
+func (m *MockDB) UpdateLocations(arg0 context.Context, arg1 netip.Prefix, arg2 vio.GeolocationPatch) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateLocations", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateLocations indicates an expected call of UpdateLocations.
+func (mr *MockDBMockRecorder) UpdateLocations(arg0, arg1, arg2 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateLocations", reflect.TypeOf((*MockDB)(nil).UpdateLocations), arg0, arg1, arg2)
+}
@@ -0,0 +1,135 @@
+// Package ratelimit implements per-client token bucket rate limiting.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit of a token bucket.
+type Limit struct {
+	// Rate at which tokens are added to the bucket, per second.
+	Rate float64
+
+	// Burst is the maximum number of tokens in the bucket.
+	Burst int
+}
+
+// Result of trying to take a token from a bucket.
+type Result struct {
+	// Allowed is true if a token was taken.
+	Allowed bool
+
+	// Limit is the bucket capacity.
+	Limit int
+
+	// Remaining tokens in the bucket.
+	Remaining int
+
+	// RetryAfter is the time until the next token is available if the request wasn't allowed.
+	RetryAfter time.Duration
+
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+}
+
+// Store of token buckets keyed by client.
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Take a token from the bucket identified by key.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// NewMemory creates an in-memory token bucket store.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Memory store for token buckets.
+// Buckets are only kept in the memory of the current process.
+type Memory struct {
+	mu        sync.Mutex // guards following
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+var _ Store = (*Memory)(nil)
+
+// sweepInterval is the minimum interval between removals of idle buckets.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill the bucket with the tokens accumulated since the last time it was used.
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed.Seconds()*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// full reports whether the bucket would be full at the given time.
+func (b *bucket) full(now time.Time) bool {
+	b.refill(now)
+	return b.tokens >= float64(b.limit.Burst)
+}
+
+// Take a token from the bucket identified by key.
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+		}
+		m.buckets[key] = b
+	}
+	b.limit = limit
+	b.refill(now)
+
+	r := Result{
+		Limit: limit.Burst,
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		r.Allowed = true
+	} else {
+		r.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	r.Remaining = int(b.tokens)
+	r.Reset = seconds((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return r, nil
+}
+
+// sweep removes buckets that are full, as they're equivalent to new ones.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if b.full(now) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(s float64) time.Duration {
+	if math.IsInf(s, 0) || math.IsNaN(s) {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMemoryTake(t *testing.T) {
+	t.Parallel()
+	var (
+		m     = NewMemory()
+		ctx   = context.Background()
+		now   = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		limit = Limit{Rate: 2, Burst: 3}
+	)
+
+	steps := []struct {
+		name    string
+		key     string
+		elapsed time.Duration
+		want    Result
+	}{
+		{
+			name: "first",
+			key:  "a",
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond},
+		},
+		{
+			name: "second",
+			key:  "a",
+			want: Result{Allowed: true, Limit: 3, Remaining: 1, Reset: time.Second},
+		},
+		{
+			name: "third",
+			key:  "a",
+			want: Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond},
+		},
+		{
+			name: "exceeded",
+			key:  "a",
+			want: Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond},
+		},
+		{
+			name: "other_client",
+			key:  "b",
+			want: Result{Allowed: true, Limit: 3, Remaining: 2, Reset: 500 * time.Millisecond},
+		},
+		{
+			name:    "refilled",
+			key:     "a",
+			elapsed: 500 * time.Millisecond,
+			want:    Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 1500 * time.Millisecond},
+		},
+	}
+	for _, s := range steps {
+		now = now.Add(s.elapsed)
+		got, err := m.Take(ctx, s.key, limit, now)
+		if err != nil {
+			t.Fatalf("%s: Memory.Take() error = %v", s.name, err)
+		}
+		if diff := cmp.Diff(s.want, got); diff != "" {
+			t.Errorf("%s: Memory.Take() mismatch: %v", s.name, diff)
+		}
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	t.Parallel()
+	var (
+		m     = NewMemory()
+		ctx   = context.Background()
+		now   = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		limit = Limit{Rate: 1, Burst: 1}
+	)
+	if _, err := m.Take(ctx, "a", limit, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Take(ctx, "b", limit, now.Add(2*sweepInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.buckets["a"]; ok {
+		t.Error("idle bucket should have been removed")
+	}
+	if _, ok := m.buckets["b"]; !ok {
+		t.Error("bucket in use should be kept")
+	}
+}
@@ -0,0 +1,20 @@
+-- Write your migrate up statements here
+
+-- api_keys table
+CREATE TABLE api_keys (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	name text NOT NULL,
+	-- Only a SHA-256 hash of the key is stored, so a leaked table doesn't leak credentials.
+	key_hash bytea NOT NULL UNIQUE,
+	scopes text[] NOT NULL,
+	created_at timestamp with time zone NOT NULL DEFAULT now(),
+	revoked_at timestamp with time zone
+);
+
+COMMENT ON COLUMN api_keys.name IS 'Name used to attribute usage to a team or client';
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TABLE api_keys;
@@ -0,0 +1,21 @@
+-- Write your migrate up statements here
+
+ALTER TABLE api_keys ADD COLUMN monthly_quota bigint CHECK (monthly_quota >= 0);
+
+COMMENT ON COLUMN api_keys.monthly_quota IS 'Maximum number of lookups per calendar month (UTC), unlimited if NULL';
+
+-- api_usage table
+CREATE TABLE api_usage (
+	-- Client is identified as key:<api key ID> or ip:<address>.
+	client text NOT NULL,
+	day date NOT NULL,
+	lookups bigint NOT NULL,
+	PRIMARY KEY (client, day)
+);
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TABLE api_usage;
+ALTER TABLE api_keys DROP COLUMN monthly_quota;
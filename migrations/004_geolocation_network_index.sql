@@ -0,0 +1,10 @@
+-- Write your migrate up statements here
+
+-- Index for finding the geolocations within a network (ip_address <<= network).
+CREATE INDEX geolocation_ip_address_network_idx ON geolocation USING gist (ip_address inet_ops);
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP INDEX geolocation_ip_address_network_idx;
@@ -0,0 +1,32 @@
+-- Write your migrate up statements here
+
+-- geolocation_overrides table with manual corrections, which take precedence over the vendor data.
+CREATE TABLE geolocation_overrides (
+	ip_address cidr PRIMARY KEY,
+	country_code text NOT NULL,
+	country text NOT NULL,
+	city text NOT NULL,
+	latitude text NOT NULL,
+	longitude text NOT NULL,
+	reason text NOT NULL,
+	author text NOT NULL,
+	updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN geolocation_overrides.reason IS 'Why the vendor data was overridden, e.g., a support ticket';
+COMMENT ON COLUMN geolocation_overrides.author IS 'Who created the override, e.g., key:<api key ID> or cli:<user>';
+
+-- geolocation_lookup combines overrides and vendor data. Overrides take precedence.
+CREATE VIEW geolocation_lookup AS
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'override' AS source
+	FROM geolocation_overrides
+	UNION ALL
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'vendor' AS source
+	FROM geolocation;
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP VIEW geolocation_lookup;
+DROP TABLE geolocation_overrides;
@@ -0,0 +1,28 @@
+-- Write your migrate up statements here
+
+-- geolocation_corrections table with the corrections proposed by clients, waiting for review.
+CREATE TABLE geolocation_corrections (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	ip_address cidr NOT NULL,
+	country_code text NOT NULL,
+	country text NOT NULL,
+	city text NOT NULL,
+	latitude text NOT NULL,
+	longitude text NOT NULL,
+	comment text NOT NULL,
+	reporter text NOT NULL,
+	status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+	created_at timestamp with time zone NOT NULL DEFAULT now(),
+	reviewer text,
+	reviewed_at timestamp with time zone
+);
+
+CREATE INDEX geolocation_corrections_status_idx ON geolocation_corrections (status, id);
+
+COMMENT ON COLUMN geolocation_corrections.reporter IS 'Client that proposed the correction: key:<api key ID> or ip:<address>';
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TABLE geolocation_corrections;
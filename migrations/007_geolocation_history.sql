@@ -0,0 +1,50 @@
+-- Write your migrate up statements here
+
+-- geolocation_history table with the previous versions of geolocations and overrides.
+-- Current versions are on the geolocation and geolocation_overrides tables.
+CREATE TABLE geolocation_history (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	ip_address cidr NOT NULL,
+	country_code text NOT NULL,
+	country text NOT NULL,
+	city text NOT NULL,
+	latitude text NOT NULL,
+	longitude text NOT NULL,
+	source text NOT NULL,
+	valid_from timestamp with time zone NOT NULL,
+	valid_to timestamp with time zone NOT NULL
+);
+
+CREATE INDEX geolocation_history_ip_address_idx ON geolocation_history (ip_address, valid_from);
+
+COMMENT ON COLUMN geolocation_history.source IS 'vendor or override';
+COMMENT ON COLUMN geolocation_history.valid_to IS 'When the version was replaced or deleted (exclusive)';
+
+CREATE FUNCTION geolocation_history_record() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO geolocation_history (ip_address, country_code, country, city, latitude, longitude, source, valid_from, valid_to)
+	VALUES (OLD.ip_address, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, TG_ARGV[0], OLD.updated_at, now());
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocation
+	FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION geolocation_history_record('vendor');
+CREATE TRIGGER geolocation_history_delete AFTER DELETE ON geolocation
+	FOR EACH ROW EXECUTE FUNCTION geolocation_history_record('vendor');
+
+CREATE TRIGGER geolocation_overrides_history_update AFTER UPDATE ON geolocation_overrides
+	FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION geolocation_history_record('override');
+CREATE TRIGGER geolocation_overrides_history_delete AFTER DELETE ON geolocation_overrides
+	FOR EACH ROW EXECUTE FUNCTION geolocation_history_record('override');
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TRIGGER geolocation_overrides_history_delete ON geolocation_overrides;
+DROP TRIGGER geolocation_overrides_history_update ON geolocation_overrides;
+DROP TRIGGER geolocation_history_delete ON geolocation;
+DROP TRIGGER geolocation_history_update ON geolocation;
+DROP FUNCTION geolocation_history_record();
+DROP TABLE geolocation_history;
@@ -0,0 +1,70 @@
+-- Write your migrate up statements here
+
+-- import_runs table with the imports of vendor data dumps.
+CREATE TABLE import_runs (
+	id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+	source text NOT NULL,
+	checksum text NOT NULL DEFAULT '',
+	operator text NOT NULL,
+	status text NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'succeeded', 'failed', 'rolled_back')),
+	accepted bigint NOT NULL DEFAULT 0,
+	discarded bigint NOT NULL DEFAULT 0,
+	error text NOT NULL DEFAULT '',
+	started_at timestamp with time zone NOT NULL DEFAULT now(),
+	finished_at timestamp with time zone
+);
+
+COMMENT ON COLUMN import_runs.source IS 'Name of the imported data dump, e.g., its file name';
+COMMENT ON COLUMN import_runs.checksum IS 'SHA-256 of the data dump, set when it is fully read';
+COMMENT ON COLUMN import_runs.operator IS 'Who ran the import, e.g., cli:<user>';
+
+-- run_id is the import run that last changed the geolocation. NULL for data edited by the admin API.
+ALTER TABLE geolocation ADD COLUMN run_id bigint REFERENCES import_runs (id);
+CREATE INDEX geolocation_run_id_idx ON geolocation (run_id);
+
+ALTER TABLE geolocation_history ADD COLUMN run_id bigint;
+
+-- Overrides have no run_id, so it is read from the row as JSON to share the trigger function.
+CREATE OR REPLACE FUNCTION geolocation_history_record() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO geolocation_history (ip_address, country_code, country, city, latitude, longitude, source, valid_from, valid_to, run_id)
+	VALUES (OLD.ip_address, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, TG_ARGV[0], OLD.updated_at, now(),
+		(to_jsonb(OLD)->>'run_id')::bigint);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- geolocation_lookup combines overrides and vendor data, with the data dump the vendor data came from.
+DROP VIEW geolocation_lookup;
+CREATE VIEW geolocation_lookup AS
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'override' AS source,
+		NULL::bigint AS run_id, '' AS dataset
+	FROM geolocation_overrides
+	UNION ALL
+	SELECT g.ip_address, g.country_code, g.country, g.city, g.latitude, g.longitude, g.updated_at, 'vendor' AS source,
+		g.run_id, COALESCE(r.source, '') AS dataset
+	FROM geolocation g LEFT JOIN import_runs r ON r.id = g.run_id;
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP VIEW geolocation_lookup;
+CREATE VIEW geolocation_lookup AS
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'override' AS source
+	FROM geolocation_overrides
+	UNION ALL
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'vendor' AS source
+	FROM geolocation;
+
+CREATE OR REPLACE FUNCTION geolocation_history_record() RETURNS trigger AS $$
+BEGIN
+	INSERT INTO geolocation_history (ip_address, country_code, country, city, latitude, longitude, source, valid_from, valid_to)
+	VALUES (OLD.ip_address, OLD.country_code, OLD.country, OLD.city, OLD.latitude, OLD.longitude, TG_ARGV[0], OLD.updated_at, now());
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE geolocation_history DROP COLUMN run_id;
+ALTER TABLE geolocation DROP COLUMN run_id;
+DROP TABLE import_runs;
@@ -0,0 +1,69 @@
+-- Write your migrate up statements here
+
+ALTER TABLE import_runs ADD COLUMN mode text NOT NULL DEFAULT 'upsert' CHECK (mode IN ('upsert', 'sync'));
+ALTER TABLE import_runs ADD COLUMN pruned bigint NOT NULL DEFAULT 0;
+
+-- pruned_by is the sync import run that soft-deleted the geolocation because it was missing from the data dump.
+-- Pruned geolocations are hidden from lookups until imported again or the import run is rolled back.
+ALTER TABLE geolocation ADD COLUMN pruned_by bigint REFERENCES import_runs (id);
+CREATE INDEX geolocation_pruned_by_idx ON geolocation (pruned_by) WHERE pruned_by IS NOT NULL;
+
+-- import_run_reimported has the geolocations pruned by a previous sync import run as they were before the import run
+-- imported them again. Pruned geolocations aren't recorded on the history, so rolling back the import run restores them from here.
+CREATE TABLE import_run_reimported (
+	run_id bigint NOT NULL REFERENCES import_runs (id) ON DELETE CASCADE,
+	ip_address cidr NOT NULL,
+	country_code text NOT NULL,
+	country text NOT NULL,
+	city text NOT NULL,
+	latitude text NOT NULL,
+	longitude text NOT NULL,
+	previous_run_id bigint,
+	pruned_by bigint NOT NULL,
+	updated_at timestamp with time zone NOT NULL,
+	PRIMARY KEY (run_id, ip_address)
+);
+
+-- A pruned geolocation isn't a valid version, so it isn't recorded on the history when it changes again.
+DROP TRIGGER geolocation_history_update ON geolocation;
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocation
+	FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.* AND OLD.pruned_by IS NULL) EXECUTE FUNCTION geolocation_history_record('vendor');
+DROP TRIGGER geolocation_history_delete ON geolocation;
+CREATE TRIGGER geolocation_history_delete AFTER DELETE ON geolocation
+	FOR EACH ROW WHEN (OLD.pruned_by IS NULL) EXECUTE FUNCTION geolocation_history_record('vendor');
+
+CREATE OR REPLACE VIEW geolocation_lookup AS
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'override' AS source,
+		NULL::bigint AS run_id, '' AS dataset
+	FROM geolocation_overrides
+	UNION ALL
+	SELECT g.ip_address, g.country_code, g.country, g.city, g.latitude, g.longitude, g.updated_at, 'vendor' AS source,
+		g.run_id, COALESCE(r.source, '') AS dataset
+	FROM geolocation g LEFT JOIN import_runs r ON r.id = g.run_id
+	WHERE g.pruned_by IS NULL;
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+CREATE OR REPLACE VIEW geolocation_lookup AS
+	SELECT ip_address, country_code, country, city, latitude, longitude, updated_at, 'override' AS source,
+		NULL::bigint AS run_id, '' AS dataset
+	FROM geolocation_overrides
+	UNION ALL
+	SELECT g.ip_address, g.country_code, g.country, g.city, g.latitude, g.longitude, g.updated_at, 'vendor' AS source,
+		g.run_id, COALESCE(r.source, '') AS dataset
+	FROM geolocation g LEFT JOIN import_runs r ON r.id = g.run_id;
+
+DROP TRIGGER geolocation_history_delete ON geolocation;
+CREATE TRIGGER geolocation_history_delete AFTER DELETE ON geolocation
+	FOR EACH ROW EXECUTE FUNCTION geolocation_history_record('vendor');
+DROP TRIGGER geolocation_history_update ON geolocation;
+CREATE TRIGGER geolocation_history_update AFTER UPDATE ON geolocation
+	FOR EACH ROW WHEN (OLD.* IS DISTINCT FROM NEW.*) EXECUTE FUNCTION geolocation_history_record('vendor');
+
+DROP TABLE import_run_reimported;
+DELETE FROM geolocation WHERE pruned_by IS NOT NULL;
+ALTER TABLE geolocation DROP COLUMN pruned_by;
+ALTER TABLE import_runs DROP COLUMN pruned;
+ALTER TABLE import_runs DROP COLUMN mode;
@@ -0,0 +1,27 @@
+-- Write your migrate up statements here
+
+-- import_run_rows has the records of a data dump staged by an import run, so they can be checked against the
+-- thresholds before changing the geolocations in a single transaction. Rows are deleted when the import run finishes.
+-- The columns have the same types and constraints as the ones of the geolocation table, so the records the database
+-- would reject are rejected when they are staged.
+CREATE UNLOGGED TABLE import_run_rows (
+	run_id bigint NOT NULL,
+	seq bigint NOT NULL,
+	ip_address cidr NOT NULL,
+	country_code text NOT NULL,
+	country text NOT NULL,
+	city text NOT NULL,
+	latitude text NOT NULL,
+	longitude text NOT NULL,
+	PRIMARY KEY (run_id, seq)
+);
+
+CREATE INDEX import_run_rows_ip_address_idx ON import_run_rows (run_id, ip_address);
+
+COMMENT ON COLUMN import_run_rows.seq IS 'Order of the record on the data dump: the last record of an IP address wins';
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TABLE import_run_rows;
@@ -0,0 +1,22 @@
+-- Write your migrate up statements here
+
+-- import_run_checkpoints has the last checkpoint of an import run: the records of the data dump up to the input offset
+-- were staged, so an interrupted import run can be resumed from there. Rows are deleted with the staged records.
+CREATE TABLE import_run_checkpoints (
+	run_id bigint PRIMARY KEY REFERENCES import_runs (id) ON DELETE CASCADE,
+	batch integer NOT NULL,
+	line bigint NOT NULL,
+	input_offset bigint NOT NULL,
+	accepted bigint NOT NULL,
+	discarded bigint NOT NULL,
+	checksum text NOT NULL,
+	updated_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+COMMENT ON COLUMN import_run_checkpoints.checksum IS 'SHA-256 of the data dump up to the input offset';
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+DROP TABLE import_run_checkpoints;
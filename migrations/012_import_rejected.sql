@@ -0,0 +1,10 @@
+-- Write your migrate up statements here
+
+-- rejected is the number of accepted records of the data dump that the database rejected when they were staged.
+ALTER TABLE import_run_checkpoints ADD COLUMN rejected bigint NOT NULL DEFAULT 0;
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+ALTER TABLE import_run_checkpoints DROP COLUMN rejected;
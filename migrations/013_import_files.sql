@@ -0,0 +1,11 @@
+-- Write your migrate up statements here
+
+-- file is the position of the file with the last record read on the list of files imported by the import run.
+-- input_offset counts the files before it.
+ALTER TABLE import_run_checkpoints ADD COLUMN file integer NOT NULL DEFAULT 0;
+
+---- create above / drop below ----
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
+ALTER TABLE import_run_checkpoints DROP COLUMN file;
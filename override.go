@@ -0,0 +1,81 @@
+package vio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Override of the vendor geolocation data of an IP address.
+// Overrides are kept when importing the vendor data, and take precedence over it.
+type Override struct {
+	IPAddress   net.IP
+	CountryCode string
+	Country     string
+	City        string
+	Latitude    json.Number
+	Longitude   json.Number
+
+	// Reason for the override, e.g., a support ticket.
+	Reason string
+
+	// Author of the override, e.g., key:<api key ID> or cli:<user>.
+	Author string
+
+	UpdatedAt time.Time
+}
+
+// Geolocation returned by lookups for the IP address of the override.
+func (o Override) Geolocation() Geolocation {
+	return Geolocation{
+		IPAddress:   o.IPAddress,
+		CountryCode: o.CountryCode,
+		Country:     o.Country,
+		City:        o.City,
+		Latitude:    o.Latitude,
+		Longitude:   o.Longitude,
+		UpdatedAt:   o.UpdatedAt,
+		Source:      SourceOverride,
+	}
+}
+
+// ErrOverrideNotFound is returned when trying to delete an override that doesn't exist.
+var ErrOverrideNotFound = errors.New("override not found")
+
+// SetOverride creates or replaces the override of the geolocation of an IP address.
+func (s *Service) SetOverride(ctx context.Context, ip string, o Override) (*Override, error) {
+	o.IPAddress = net.ParseIP(ip)
+	if o.IPAddress == nil {
+		return nil, ErrBadIPAddressFormat
+	}
+	if err := o.Geolocation().Validate(); err != nil {
+		return nil, err
+	}
+	if o.Reason == "" {
+		return nil, fmt.Errorf("%w: missing reason", ErrInvalidGeolocation)
+	}
+	if o.Author == "" {
+		return nil, fmt.Errorf("%w: missing author", ErrInvalidGeolocation)
+	}
+	if err := s.db.PutOverride(ctx, &o); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ListOverrides returns all overrides.
+func (s *Service) ListOverrides(ctx context.Context) ([]Override, error) {
+	return s.db.ListOverrides(ctx)
+}
+
+// DeleteOverride deletes the override of an IP address, restoring the vendor data.
+func (s *Service) DeleteOverride(ctx context.Context, ip string) error {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ErrBadIPAddressFormat
+	}
+	return s.db.DeleteOverride(ctx, addr)
+}
@@ -3,11 +3,15 @@ package vio
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
+	"net/netip"
+	"time"
 
 	"github.com/henvic/pgtools"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -25,27 +29,65 @@ type Postgres struct {
 	log *slog.Logger
 }
 
-// lookupLocationQuery used to get a geolocation from the database.
+// lookupLocationQuery used to get a geolocation from the database, preferring overrides to the vendor data.
 // Or rather:
-// var lookupLocationQuery = `SELECT ip_address,country_code,country,city,latitude,longitude,updated_at FROM geolocation WHERE ip_address = $1 LIMIT 1;`
-var lookupLocationQuery = `SELECT ` + pgtools.Wildcard(Geolocation{}) + ` FROM geolocation WHERE ip_address = $1 LIMIT 1;`
+// var lookupLocationQuery = `SELECT ip_address,country_code,country,city,latitude,longitude,updated_at,source,run_id,dataset FROM geolocation_lookup WHERE ip_address = $1 ORDER BY source = 'override' DESC LIMIT 1;`
+var lookupLocationQuery = `SELECT ` + pgtools.Wildcard(Geolocation{}) + ` FROM geolocation_lookup WHERE ip_address = $1 ORDER BY source = 'override' DESC LIMIT 1;`
 
 // LookupLocation returns a location.
 func (pg Postgres) LookupLocation(ctx context.Context, ip net.IP) (*Geolocation, error) {
 	rows, err := pg.pool.Query(ctx, lookupLocationQuery, ip)
-	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+	var loc Geolocation
+	if err == nil {
+		loc, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[Geolocation])
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
 		return nil, err
+	case isQueryTimeout(err):
+		return nil, ErrQueryTimeout
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	}
+	if err != nil {
+		pg.log.Error("cannot get location from database",
+			slog.Any("ip", ip),
+			slog.Any("error", err),
+		)
+		return nil, errors.New("cannot get location from database")
 	}
+	return &loc, nil
+}
+
+// lookupLocationAtQuery used to get the geolocation valid at a given time, preferring overrides to the vendor data.
+var lookupLocationAtQuery = `SELECT ` + pgtools.Wildcard(Geolocation{}) + ` FROM (
+SELECT ` + pgtools.Wildcard(Geolocation{}) + ` FROM geolocation_lookup WHERE ip_address = $1 AND updated_at <= $2
+UNION ALL
+SELECT h.ip_address, h.country_code, h.country, h.city, h.latitude, h.longitude, h.valid_from AS updated_at, h.source,
+h.run_id, COALESCE(r.source, '') AS dataset
+FROM geolocation_history h LEFT JOIN import_runs r ON r.id = h.run_id
+WHERE h.ip_address = $1 AND h.valid_from <= $2 AND h.valid_to > $2
+) AS versions ORDER BY source = 'override' DESC, updated_at DESC LIMIT 1;`
+
+// LookupLocationAt returns the location valid at the given time.
+func (pg Postgres) LookupLocationAt(ctx context.Context, ip net.IP, at time.Time) (*Geolocation, error) {
+	rows, err := pg.pool.Query(ctx, lookupLocationAtQuery, ip, at)
 	var loc Geolocation
 	if err == nil {
 		loc, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[Geolocation])
 	}
-	if errors.Is(err, pgx.ErrNoRows) {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case isQueryTimeout(err):
+		return nil, ErrQueryTimeout
+	case errors.Is(err, pgx.ErrNoRows):
 		return nil, nil
 	}
 	if err != nil {
-		pg.log.Error("cannot get location from database",
+		pg.log.Error("cannot get location at time from database",
 			slog.Any("ip", ip),
+			slog.Time("at", at),
 			slog.Any("error", err),
 		)
 		return nil, errors.New("cannot get location from database")
@@ -53,8 +95,72 @@ func (pg Postgres) LookupLocation(ctx context.Context, ip net.IP) (*Geolocation,
 	return &loc, nil
 }
 
-// importQuery used to insert data into the database.
+// getHistoryQuery used to list the current and previous versions of the geolocation of an IP address.
+var getHistoryQuery = `SELECT ` + pgtools.Wildcard(GeolocationVersion{}) + ` FROM (
+SELECT ip_address, country_code, country, city, latitude, longitude, source, run_id, updated_at AS valid_from, NULL::timestamp with time zone AS valid_to
+FROM geolocation_lookup WHERE ip_address = $1
+UNION ALL
+SELECT ip_address, country_code, country, city, latitude, longitude, source, run_id, valid_from, valid_to
+FROM geolocation_history WHERE ip_address = $1
+) AS versions ORDER BY valid_from DESC, source LIMIT $2;`
+
+// GetHistory returns the versions of the geolocation of an IP address, most recent first.
+func (pg Postgres) GetHistory(ctx context.Context, ip net.IP, limit int) ([]GeolocationVersion, error) {
+	rows, err := pg.pool.Query(ctx, getHistoryQuery, ip, limit)
+	var versions []GeolocationVersion
+	if err == nil {
+		versions, err = pgx.CollectRows(rows, pgx.RowToStructByPos[GeolocationVersion])
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case isQueryTimeout(err):
+		return nil, ErrQueryTimeout
+	}
+	if err != nil {
+		pg.log.Error("cannot get history from database",
+			slog.Any("ip", ip),
+			slog.Any("error", err),
+		)
+		return nil, errors.New("cannot get history from database")
+	}
+	return versions, nil
+}
+
+// isQueryTimeout checks whether a query was canceled by the statement_timeout of the database.
+func isQueryTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	// query_canceled: https://www.postgresql.org/docs/current/errcodes-appendix.html
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// importQuery used to create or update the geolocations from the records staged by an import run.
+// Existing rows are only updated if their data changed, so updated_at and the history reflect actual changes.
 const importQuery = `INSERT INTO geolocation (
+ip_address, country_code, country, city, latitude, longitude, run_id
+) ` + incomingRows + `
+ON CONFLICT (ip_address) DO UPDATE SET
+country_code = EXCLUDED.country_code,
+country = EXCLUDED.country,
+city = EXCLUDED.city,
+latitude = EXCLUDED.latitude,
+longitude = EXCLUDED.longitude,
+run_id = EXCLUDED.run_id,
+pruned_by = NULL,
+updated_at = now()
+WHERE (geolocation.country_code, geolocation.country, geolocation.city, geolocation.latitude, geolocation.longitude, geolocation.pruned_by)
+IS DISTINCT FROM (EXCLUDED.country_code, EXCLUDED.country, EXCLUDED.city, EXCLUDED.latitude, EXCLUDED.longitude, EXCLUDED.pruned_by);`
+
+// reimportedQuery used to keep the geolocations pruned by previous sync import runs that are imported again,
+// before importQuery, so the import run can be rolled back.
+const reimportedQuery = `INSERT INTO import_run_reimported (
+run_id, ip_address, country_code, country, city, latitude, longitude, previous_run_id, pruned_by, updated_at
+) SELECT $1, g.ip_address, g.country_code, g.country, g.city, g.latitude, g.longitude, g.run_id, g.pruned_by, g.updated_at
+FROM geolocation g
+WHERE g.pruned_by IS NOT NULL AND EXISTS (SELECT 1 FROM import_run_rows s WHERE s.run_id = $1 AND s.ip_address = g.ip_address);`
+
+// putLocationQuery used to create or replace a geolocation.
+const putLocationQuery = `INSERT INTO geolocation (
 ip_address, country_code, country, city, latitude, longitude
 ) VALUES ($1, $2, $3, $4, $5, $6)
 ON CONFLICT (ip_address) DO UPDATE SET
@@ -63,4 +169,539 @@ country = EXCLUDED.country,
 city = EXCLUDED.city,
 latitude = EXCLUDED.latitude,
 longitude = EXCLUDED.longitude,
-updated_at = now();`
+run_id = NULL,
+pruned_by = NULL,
+updated_at = now()
+RETURNING updated_at, xmax = 0;`
+
+// PutLocation creates or replaces a geolocation, setting its update time. It returns whether it was created.
+func (pg Postgres) PutLocation(ctx context.Context, loc *Geolocation) (created bool, err error) {
+	err = pg.pool.QueryRow(ctx, putLocationQuery,
+		loc.IPAddress,
+		loc.CountryCode,
+		loc.Country,
+		loc.City,
+		loc.Latitude,
+		loc.Longitude,
+	).Scan(&loc.UpdatedAt, &created)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, err
+	}
+	if err != nil {
+		pg.log.Error("cannot put location on database",
+			slog.Any("ip", loc.IPAddress),
+			slog.Any("error", err),
+		)
+		return false, errors.New("cannot put location on database")
+	}
+	loc.Source = SourceVendor
+	return created, nil
+}
+
+// updateLocationsQuery used to change the geolocations within a network, keeping the fields set to NULL.
+const updateLocationsQuery = `UPDATE geolocation SET
+country_code = COALESCE($2, country_code),
+country = COALESCE($3, country),
+city = COALESCE($4, city),
+latitude = COALESCE($5, latitude),
+longitude = COALESCE($6, longitude),
+run_id = NULL,
+updated_at = now()
+WHERE ip_address <<= $1 AND pruned_by IS NULL;`
+
+// UpdateLocations changes the geolocations within the network, returning how many were changed.
+func (pg Postgres) UpdateLocations(ctx context.Context, network netip.Prefix, patch GeolocationPatch) (int64, error) {
+	ct, err := pg.pool.Exec(ctx, updateLocationsQuery,
+		mappedPrefix(network),
+		patch.CountryCode,
+		patch.Country,
+		patch.City,
+		patch.Latitude,
+		patch.Longitude,
+	)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, err
+	}
+	if err != nil {
+		pg.log.Error("cannot update locations on database",
+			slog.Any("network", network),
+			slog.Any("error", err),
+		)
+		return 0, errors.New("cannot update locations on database")
+	}
+	return ct.RowsAffected(), nil
+}
+
+// DeleteLocations deletes the geolocations within the network, returning how many were deleted.
+func (pg Postgres) DeleteLocations(ctx context.Context, network netip.Prefix) (int64, error) {
+	const sql = `DELETE FROM geolocation WHERE ip_address <<= $1 AND pruned_by IS NULL`
+	ct, err := pg.pool.Exec(ctx, sql, mappedPrefix(network))
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, err
+	}
+	if err != nil {
+		pg.log.Error("cannot delete locations from database",
+			slog.Any("network", network),
+			slog.Any("error", err),
+		)
+		return 0, errors.New("cannot delete locations from database")
+	}
+	return ct.RowsAffected(), nil
+}
+
+// putOverrideQuery used to create or replace an override.
+const putOverrideQuery = `INSERT INTO geolocation_overrides (
+ip_address, country_code, country, city, latitude, longitude, reason, author
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (ip_address) DO UPDATE SET
+country_code = EXCLUDED.country_code,
+country = EXCLUDED.country,
+city = EXCLUDED.city,
+latitude = EXCLUDED.latitude,
+longitude = EXCLUDED.longitude,
+reason = EXCLUDED.reason,
+author = EXCLUDED.author,
+updated_at = now()
+RETURNING updated_at;`
+
+// PutOverride creates or replaces an override, setting its update time.
+func (pg Postgres) PutOverride(ctx context.Context, o *Override) error {
+	err := pg.pool.QueryRow(ctx, putOverrideQuery,
+		o.IPAddress,
+		o.CountryCode,
+		o.Country,
+		o.City,
+		o.Latitude,
+		o.Longitude,
+		o.Reason,
+		o.Author,
+	).Scan(&o.UpdatedAt)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot put override on database",
+			slog.Any("ip", o.IPAddress),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot put override on database")
+	}
+	return nil
+}
+
+// listOverridesQuery used to list all overrides.
+var listOverridesQuery = `SELECT ` + pgtools.Wildcard(Override{}) + ` FROM geolocation_overrides ORDER BY ip_address`
+
+// ListOverrides returns all overrides.
+func (pg Postgres) ListOverrides(ctx context.Context) ([]Override, error) {
+	rows, err := pg.pool.Query(ctx, listOverridesQuery)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	var overrides []Override
+	if err == nil {
+		overrides, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Override])
+	}
+	if err != nil {
+		pg.log.Error("cannot list overrides from database", slog.Any("error", err))
+		return nil, errors.New("cannot list overrides from database")
+	}
+	return overrides, nil
+}
+
+// DeleteOverride deletes the override of an IP address.
+func (pg Postgres) DeleteOverride(ctx context.Context, ip net.IP) error {
+	const sql = `DELETE FROM geolocation_overrides WHERE ip_address = $1`
+	ct, err := pg.pool.Exec(ctx, sql, ip)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot delete override from database",
+			slog.Any("ip", ip),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot delete override from database")
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
+
+// CreateCorrection stores a new correction, setting its ID and creation time.
+func (pg Postgres) CreateCorrection(ctx context.Context, c *Correction) error {
+	const sql = `INSERT INTO geolocation_corrections (
+ip_address, country_code, country, city, latitude, longitude, comment, reporter
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created_at`
+	err := pg.pool.QueryRow(ctx, sql,
+		c.IPAddress,
+		c.CountryCode,
+		c.Country,
+		c.City,
+		c.Latitude,
+		c.Longitude,
+		c.Comment,
+		c.Reporter,
+	).Scan(&c.ID, &c.CreatedAt)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot create correction on database",
+			slog.Any("ip", c.IPAddress),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot create correction on database")
+	}
+	return nil
+}
+
+// listCorrectionsQuery used to list corrections, optionally filtered by status.
+var listCorrectionsQuery = `SELECT ` + pgtools.Wildcard(Correction{}) + ` FROM geolocation_corrections WHERE $1 = '' OR status = $1 ORDER BY id`
+
+// ListCorrections returns the corrections with the given status, or all corrections if status is empty.
+func (pg Postgres) ListCorrections(ctx context.Context, status string) ([]Correction, error) {
+	rows, err := pg.pool.Query(ctx, listCorrectionsQuery, status)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	var corrections []Correction
+	if err == nil {
+		corrections, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Correction])
+	}
+	if err != nil {
+		pg.log.Error("cannot list corrections from database", slog.Any("error", err))
+		return nil, errors.New("cannot list corrections from database")
+	}
+	return corrections, nil
+}
+
+// reviewCorrectionQuery used to set the status of a pending correction.
+var reviewCorrectionQuery = `UPDATE geolocation_corrections SET status = $2, reviewer = $3, reviewed_at = now()
+WHERE id = $1 AND status = 'pending' RETURNING ` + pgtools.Wildcard(Correction{})
+
+// ReviewCorrection sets the status of a pending correction, creating an override from it if approved.
+func (pg Postgres) ReviewCorrection(ctx context.Context, id int64, status, reviewer string) (*Correction, error) {
+	var c Correction
+	err := pgx.BeginFunc(ctx, pg.pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, reviewCorrectionQuery, id, status, reviewer)
+		if err == nil {
+			c, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[Correction])
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			var exists bool
+			if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM geolocation_corrections WHERE id = $1)`, id).Scan(&exists); err != nil {
+				return err
+			}
+			if exists {
+				return ErrCorrectionReviewed
+			}
+			return ErrCorrectionNotFound
+		}
+		if err != nil || status != CorrectionApproved {
+			return err
+		}
+		_, err = tx.Exec(ctx, putOverrideQuery,
+			c.IPAddress,
+			c.CountryCode,
+			c.Country,
+			c.City,
+			c.Latitude,
+			c.Longitude,
+			fmt.Sprintf("correction %d", c.ID),
+			reviewer,
+		)
+		return err
+	})
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case err == ErrCorrectionReviewed, err == ErrCorrectionNotFound:
+		return nil, err
+	case err != nil:
+		pg.log.Error("cannot review correction on database",
+			slog.Int64("id", id),
+			slog.Any("error", err),
+		)
+		return nil, errors.New("cannot review correction on database")
+	}
+	return &c, nil
+}
+
+// listImportRunsQuery used to list all import runs.
+var listImportRunsQuery = `SELECT ` + pgtools.Wildcard(ImportRun{}) + ` FROM import_runs ORDER BY id DESC`
+
+// ListImportRuns returns all import runs, most recent first.
+func (pg Postgres) ListImportRuns(ctx context.Context) ([]ImportRun, error) {
+	rows, err := pg.pool.Query(ctx, listImportRunsQuery)
+	var runs []ImportRun
+	if err == nil {
+		runs, err = pgx.CollectRows(rows, pgx.RowToStructByPos[ImportRun])
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		pg.log.Error("cannot list import runs from database", slog.Any("error", err))
+		return nil, errors.New("cannot list import runs from database")
+	}
+	return runs, nil
+}
+
+// restoreImportRunQuery used to restore the geolocations last changed by an import run
+// to the vendor version that was current when the import run started.
+const restoreImportRunQuery = `UPDATE geolocation g SET
+country_code = h.country_code,
+country = h.country,
+city = h.city,
+latitude = h.latitude,
+longitude = h.longitude,
+run_id = h.run_id,
+updated_at = now()
+FROM geolocation_history h, import_runs r
+WHERE r.id = $1 AND g.run_id = r.id
+AND h.ip_address = g.ip_address AND h.source = 'vendor'
+AND h.valid_from < r.started_at AND h.valid_to >= r.started_at;`
+
+// repruneImportRunQuery used to restore the geolocations pruned before the import run imported them again as they were.
+// They are pruned again, unless the import run that pruned them was rolled back meanwhile.
+const repruneImportRunQuery = `UPDATE geolocation g SET
+country_code = p.country_code,
+country = p.country,
+city = p.city,
+latitude = p.latitude,
+longitude = p.longitude,
+run_id = p.previous_run_id,
+pruned_by = CASE WHEN r.status = 'rolled_back' THEN NULL ELSE p.pruned_by END,
+updated_at = p.updated_at
+FROM import_run_reimported p, import_runs r
+WHERE p.run_id = $1 AND g.run_id = $1 AND g.ip_address = p.ip_address AND r.id = p.pruned_by;`
+
+// RollbackImportRun reverts the geolocations last changed by the import run to their previous versions,
+// deleting the ones it created and restoring the ones it pruned. Geolocations pruned before it imported them again are pruned again.
+func (pg Postgres) RollbackImportRun(ctx context.Context, id int64) (*ImportRollback, error) {
+	var rollback ImportRollback
+	err := pgx.BeginFunc(ctx, pg.pool, func(tx pgx.Tx) error {
+		var status string
+		err := tx.QueryRow(ctx, `SELECT status FROM import_runs WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return ErrImportRunNotFound
+		case err != nil:
+			return err
+		case status == ImportRunRunning:
+			return ErrImportRunNotFinished
+		case status == ImportRunRolledBack:
+			return ErrImportRunRolledBack
+		}
+
+		ct, err := tx.Exec(ctx, repruneImportRunQuery, id)
+		if err != nil {
+			return err
+		}
+		rollback.Restored = ct.RowsAffected()
+
+		if ct, err = tx.Exec(ctx, restoreImportRunQuery, id); err != nil {
+			return err
+		}
+		rollback.Restored += ct.RowsAffected()
+
+		// The remaining geolocations didn't exist before the import run.
+		if ct, err = tx.Exec(ctx, `DELETE FROM geolocation WHERE run_id = $1`, id); err != nil {
+			return err
+		}
+		rollback.Deleted = ct.RowsAffected()
+
+		const unpruneQuery = `UPDATE geolocation SET pruned_by = NULL, updated_at = now() WHERE pruned_by = $1`
+		if ct, err = tx.Exec(ctx, unpruneQuery, id); err != nil {
+			return err
+		}
+		rollback.Unpruned = ct.RowsAffected()
+
+		// A failed import run can't be resumed once rolled back.
+		if _, err = tx.Exec(ctx, `DELETE FROM import_run_rows WHERE run_id = $1`, id); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(ctx, `DELETE FROM import_run_checkpoints WHERE run_id = $1`, id); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE import_runs SET status = $2 WHERE id = $1`, id, ImportRunRolledBack)
+		return err
+	})
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case err == ErrImportRunNotFound, err == ErrImportRunNotFinished, err == ErrImportRunRolledBack:
+		return nil, err
+	case err != nil:
+		pg.log.Error("cannot roll back import run on database",
+			slog.Int64("id", id),
+			slog.Any("error", err),
+		)
+		return nil, errors.New("cannot roll back import run on database")
+	}
+	return &rollback, nil
+}
+
+// mappedPrefix converts an IPv4 network to an IPv4-mapped IPv6 network.
+// IP addresses are stored in this form because pgx encodes the 16-byte net.IP values from net.ParseIP as IPv6.
+func mappedPrefix(p netip.Prefix) netip.Prefix {
+	if !p.Addr().Is4() {
+		return p
+	}
+	return netip.PrefixFrom(netip.AddrFrom16(p.Addr().As16()), p.Bits()+96)
+}
+
+// CreateAPIKey stores a new API key, setting its ID and creation time.
+func (pg Postgres) CreateAPIKey(ctx context.Context, key *APIKey, hash []byte) error {
+	const sql = `INSERT INTO api_keys (name, key_hash, scopes, monthly_quota) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := pg.pool.QueryRow(ctx, sql, key.Name, hash, key.Scopes, key.MonthlyQuota).Scan(&key.ID, &key.CreatedAt)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot create API key on database",
+			slog.String("name", key.Name),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot create API key on database")
+	}
+	return nil
+}
+
+// getAPIKeyQuery used to get an API key by its hash.
+var getAPIKeyQuery = `SELECT ` + pgtools.Wildcard(APIKey{}) + ` FROM api_keys WHERE key_hash = $1 LIMIT 1`
+
+// GetAPIKey returns the API key with the given hash.
+func (pg Postgres) GetAPIKey(ctx context.Context, hash []byte) (*APIKey, error) {
+	rows, err := pg.pool.Query(ctx, getAPIKeyQuery, hash)
+	var key APIKey
+	if err == nil {
+		key, err = pgx.CollectOneRow(rows, pgx.RowToStructByPos[APIKey])
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case isQueryTimeout(err):
+		return nil, ErrQueryTimeout
+	case errors.Is(err, pgx.ErrNoRows):
+		return nil, nil
+	}
+	if err != nil {
+		pg.log.Error("cannot get API key from database", slog.Any("error", err))
+		return nil, errors.New("cannot get API key from database")
+	}
+	return &key, nil
+}
+
+// listAPIKeysQuery used to list all API keys.
+var listAPIKeysQuery = `SELECT ` + pgtools.Wildcard(APIKey{}) + ` FROM api_keys ORDER BY id`
+
+// ListAPIKeys returns all API keys.
+func (pg Postgres) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := pg.pool.Query(ctx, listAPIKeysQuery)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	var keys []APIKey
+	if err == nil {
+		keys, err = pgx.CollectRows(rows, pgx.RowToStructByPos[APIKey])
+	}
+	if err != nil {
+		pg.log.Error("cannot list API keys from database", slog.Any("error", err))
+		return nil, errors.New("cannot list API keys from database")
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key.
+func (pg Postgres) RevokeAPIKey(ctx context.Context, id int64) error {
+	const sql = `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`
+	ct, err := pg.pool.Exec(ctx, sql, id)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot revoke API key on database",
+			slog.Int64("id", id),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot revoke API key on database")
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// SetAPIKeyQuota sets the monthly quota of an API key.
+func (pg Postgres) SetAPIKeyQuota(ctx context.Context, id int64, monthlyQuota *int64) error {
+	const sql = `UPDATE api_keys SET monthly_quota = $2 WHERE id = $1`
+	ct, err := pg.pool.Exec(ctx, sql, id, monthlyQuota)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot set API key quota on database",
+			slog.Int64("id", id),
+			slog.Any("error", err),
+		)
+		return errors.New("cannot set API key quota on database")
+	}
+	if ct.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AddUsage adds the usage counters to the existing ones.
+func (pg Postgres) AddUsage(ctx context.Context, usage []Usage) error {
+	const sql = `INSERT INTO api_usage (client, day, lookups)
+SELECT * FROM unnest($1::text[], $2::date[], $3::bigint[])
+ON CONFLICT (client, day) DO UPDATE SET lookups = api_usage.lookups + EXCLUDED.lookups`
+	var (
+		clients = make([]string, len(usage))
+		days    = make([]time.Time, len(usage))
+		lookups = make([]int64, len(usage))
+	)
+	for i, u := range usage {
+		clients[i], days[i], lookups[i] = u.Client, u.Day, u.Lookups
+	}
+	_, err := pg.pool.Exec(ctx, sql, clients, days, lookups)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if err != nil {
+		pg.log.Error("cannot add usage on database", slog.Any("error", err))
+		return errors.New("cannot add usage on database")
+	}
+	return nil
+}
+
+// getUsageQuery used to get the daily usage of a client.
+var getUsageQuery = `SELECT ` + pgtools.Wildcard(Usage{}) + ` FROM api_usage WHERE client = $1 AND day BETWEEN $2 AND $3 ORDER BY day`
+
+// GetUsage returns the daily usage of a client in the [from, to] interval of days.
+func (pg Postgres) GetUsage(ctx context.Context, client string, from, to time.Time) ([]Usage, error) {
+	rows, err := pg.pool.Query(ctx, getUsageQuery, client, from, to)
+	var usage []Usage
+	if err == nil {
+		usage, err = pgx.CollectRows(rows, pgx.RowToStructByPos[Usage])
+	}
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return nil, err
+	case isQueryTimeout(err):
+		return nil, ErrQueryTimeout
+	}
+	if err != nil {
+		pg.log.Error("cannot get usage from database",
+			slog.String("client", client),
+			slog.Any("error", err),
+		)
+		return nil, errors.New("cannot get usage from database")
+	}
+	return usage, nil
+}
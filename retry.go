@@ -0,0 +1,105 @@
+package vio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ImportRetry of batches, and of applying the import, when they fail with transient database errors,
+// with exponential backoff and jitter.
+type ImportRetry struct {
+	// MaxAttempts to process a batch or to apply the import, including the first one (default 1, no retries).
+	MaxAttempts int
+
+	// Backoff before the first retry, doubled on each retry (default 100ms).
+	// A random duration between half and the whole backoff is waited.
+	Backoff time.Duration
+
+	// MaxBackoff between retries (default 10s).
+	MaxBackoff time.Duration
+}
+
+// retryCount of the batches of an import.
+type retryCount struct {
+	retries atomic.Int64
+	batches atomic.Int64
+}
+
+// retry the function while it fails with a transient database error, up to the maximum attempts.
+// The operation identifies what is retried on the log, such as the number of the batch.
+func (i *Importer) retry(ctx context.Context, op slog.Attr, count *retryCount, fn func(attempt int) error) error {
+	backoff := i.opts.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || ctx.Err() != nil || !isTransient(err) {
+			return err
+		}
+		if attempt >= i.opts.Retry.MaxAttempts {
+			if attempt > 1 {
+				err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+		if attempt == 1 {
+			count.batches.Add(1)
+		}
+		count.retries.Add(1)
+
+		wait := backoff/2 + rand.N(backoff/2+1)
+		i.log.Warn("Retrying after transient database error",
+			op,
+			slog.Int("attempt", attempt),
+			slog.Duration("wait", wait),
+			slog.Any("error", err),
+		)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		backoff = min(backoff*2, i.opts.Retry.MaxBackoff)
+	}
+}
+
+// isTransient checks if the database error is likely to go away if the operation is retried:
+// serialization failures, deadlocks, and lost connections.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// https://www.postgresql.org/docs/current/errcodes-appendix.html
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08: connection exception.
+		return strings.HasPrefix(pgErr.Code, "08")
+	}
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// isRecordError checks if the database rejected the data of a record: data exceptions, such as invalid text encoding,
+// and integrity constraint violations.
+func isRecordError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
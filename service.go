@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"time"
 )
 
 // ErrBadIPAddressFormat is returned when lookin up using a bad IP address format.
 var ErrBadIPAddressFormat = fmt.Errorf("invalid IP address format")
 
+// ErrQueryTimeout is returned when a database query takes longer than the statement timeout.
+var ErrQueryTimeout = errors.New("database query timed out")
+
 // LookupLocation returns a location.
 func (s *Service) LookupLocation(ctx context.Context, ip string) (*Geolocation, error) {
 	addr := net.ParseIP(ip)
@@ -19,6 +24,27 @@ func (s *Service) LookupLocation(ctx context.Context, ip string) (*Geolocation,
 	return s.db.LookupLocation(ctx, addr)
 }
 
+// LookupLocationAt returns the location valid at the given time.
+func (s *Service) LookupLocationAt(ctx context.Context, ip string, at time.Time) (*Geolocation, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, ErrBadIPAddressFormat
+	}
+	return s.db.LookupLocationAt(ctx, addr, at)
+}
+
+// maxHistory is the maximum number of versions returned by History.
+const maxHistory = 1000
+
+// History returns the current and previous versions of the geolocation of an IP address, most recent first.
+func (s *Service) History(ctx context.Context, ip string) ([]GeolocationVersion, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return nil, ErrBadIPAddressFormat
+	}
+	return s.db.GetHistory(ctx, addr, maxHistory)
+}
+
 // NewService creates an API service.
 func NewService(db DB) *Service {
 	return &Service{db: db}
@@ -35,6 +61,66 @@ type Service struct {
 type DB interface {
 	// LookupLocation returns a location.
 	LookupLocation(ctx context.Context, ip net.IP) (*Geolocation, error)
+
+	// LookupLocationAt returns the location valid at the given time.
+	LookupLocationAt(ctx context.Context, ip net.IP, at time.Time) (*Geolocation, error)
+
+	// GetHistory returns up to limit versions of the geolocation of an IP address, most recent first.
+	GetHistory(ctx context.Context, ip net.IP, limit int) ([]GeolocationVersion, error)
+
+	// PutLocation creates or replaces a geolocation, setting its update time. It returns whether it was created.
+	PutLocation(ctx context.Context, loc *Geolocation) (bool, error)
+
+	// UpdateLocations changes the geolocations within the network, returning how many were changed.
+	UpdateLocations(ctx context.Context, network netip.Prefix, patch GeolocationPatch) (int64, error)
+
+	// DeleteLocations deletes the geolocations within the network, returning how many were deleted.
+	DeleteLocations(ctx context.Context, network netip.Prefix) (int64, error)
+
+	// PutOverride creates or replaces an override, setting its update time.
+	PutOverride(ctx context.Context, o *Override) error
+
+	// ListOverrides returns all overrides.
+	ListOverrides(ctx context.Context) ([]Override, error)
+
+	// DeleteOverride deletes the override of an IP address.
+	DeleteOverride(ctx context.Context, ip net.IP) error
+
+	// CreateCorrection stores a new correction, setting its ID and creation time.
+	CreateCorrection(ctx context.Context, c *Correction) error
+
+	// ListCorrections returns the corrections with the given status, or all corrections if status is empty.
+	ListCorrections(ctx context.Context, status string) ([]Correction, error)
+
+	// ReviewCorrection sets the status of a pending correction, creating an override from it if approved.
+	ReviewCorrection(ctx context.Context, id int64, status, reviewer string) (*Correction, error)
+
+	// ListImportRuns returns all import runs, most recent first.
+	ListImportRuns(ctx context.Context) ([]ImportRun, error)
+
+	// RollbackImportRun reverts the geolocations last changed by the import run to their previous versions.
+	RollbackImportRun(ctx context.Context, id int64) (*ImportRollback, error)
+
+	// CreateAPIKey stores a new API key, setting its ID and creation time.
+	CreateAPIKey(ctx context.Context, key *APIKey, hash []byte) error
+
+	// GetAPIKey returns the API key with the given hash.
+	GetAPIKey(ctx context.Context, hash []byte) (*APIKey, error)
+
+	// ListAPIKeys returns all API keys.
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+
+	// RevokeAPIKey revokes an API key.
+	RevokeAPIKey(ctx context.Context, id int64) error
+
+	// SetAPIKeyQuota sets the monthly quota of an API key.
+	SetAPIKeyQuota(ctx context.Context, id int64, monthlyQuota *int64) error
+
+	// AddUsage adds the usage counters to the existing ones.
+	AddUsage(ctx context.Context, usage []Usage) error
+
+	// GetUsage returns the daily usage of a client in the [from, to] interval of days.
+	GetUsage(ctx context.Context, client string, from, to time.Time) ([]Usage, error)
 }
 
 var _ DB = (*Postgres)(nil) // Check if methods expected by geolocation.DB are implemented correctly.
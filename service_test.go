@@ -45,13 +45,14 @@ func TestServiceLookupLocation(t *testing.T) {
 	}
 	defer file.Close()
 
-	stats, err := vio.NewImporter(3, slog.Default(), pool).Stream(context.Background(), file)
+	stats, err := vio.NewImporter(slog.Default(), pool, vio.ImportOptions{BatchSize: 3}).Stream(context.Background(), file, vio.ImportSource{Name: "example.csv", Operator: "test"})
 	if stats == nil {
 		t.Error("stats should not be nil")
 	}
 	if err != nil {
 		t.Errorf("cannot import location data: %v", err)
 	}
+	runID := int64(1) // First import run of the test database.
 
 	type args struct {
 		ctx context.Context
@@ -94,6 +95,9 @@ func TestServiceLookupLocation(t *testing.T) {
 				Latitude:    "-68.31023296602508",
 				Longitude:   "-37.62435199624531",
 				UpdatedAt:   time.Now(),
+				Source:      vio.SourceVendor,
+				RunID:       &runID,
+				Dataset:     "example.csv",
 			},
 		},
 		{
@@ -110,6 +114,9 @@ func TestServiceLookupLocation(t *testing.T) {
 				Latitude:    "-78.2274228596799",
 				Longitude:   "-163.26218895343357",
 				UpdatedAt:   time.Now(),
+				Source:      vio.SourceVendor,
+				RunID:       &runID,
+				Dataset:     "example.csv",
 			},
 		},
 		{
@@ -126,6 +133,9 @@ func TestServiceLookupLocation(t *testing.T) {
 				Latitude:    "-78.2274228596799",
 				Longitude:   "-163.26218895343357",
 				UpdatedAt:   time.Now(),
+				Source:      vio.SourceVendor,
+				RunID:       &runID,
+				Dataset:     "example.csv",
 			},
 		},
 		{
@@ -0,0 +1,117 @@
+package vio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ImportThresholds abort an import before it changes the geolocations, as the data dump is likely bad.
+// Zero values disable them.
+type ImportThresholds struct {
+	// MaxDiscardRatio is the maximum fraction of the records of the data dump that may be discarded.
+	MaxDiscardRatio float64
+
+	// MaxChangeRatio is the maximum fraction of the current geolocations the import may change.
+	MaxChangeRatio float64
+
+	// MinRecords is the minimum number of records of the data dump that must be accepted.
+	MinRecords int
+
+	// MaxCountryDelta is the maximum fraction by which the number of geolocations of a country may change,
+	// relative to the largest of the numbers before and after the import.
+	MaxCountryDelta float64
+}
+
+// ErrImportThreshold is returned when an import exceeds its thresholds.
+var ErrImportThreshold = errors.New("import threshold exceeded")
+
+// importMetrics of a data dump against the current geolocations, checked against the thresholds.
+type importMetrics struct {
+	accepted  int
+	discarded int
+
+	// current number of geolocations.
+	current int
+
+	// incoming is the number of IP addresses of the data dump, and created is the number of them without a current geolocation.
+	incoming int
+	created  int
+
+	// changed is the number of current geolocations the import changes.
+	changed int
+
+	// missing is the number of current geolocations missing from the data dump, which a sync import prunes.
+	missing int
+	prune   int
+
+	// countries has the number of geolocations of each country before and after the import.
+	countries map[string]countryCount
+}
+
+// countryCount is the number of geolocations of a country before and after an import.
+type countryCount struct {
+	before int
+	after  int
+}
+
+// check the metrics of the import against the thresholds and the MaxPruneRatio limit.
+func (i *Importer) check(m importMetrics) error {
+	var (
+		t    = i.opts.Thresholds
+		errs []error
+	)
+	if t.MinRecords > 0 && m.accepted < t.MinRecords {
+		errs = append(errs, fmt.Errorf("%w: %d records accepted, below the minimum of %d",
+			ErrImportThreshold, m.accepted, t.MinRecords))
+	}
+	if records := m.accepted + m.discarded; t.MaxDiscardRatio > 0 && records > 0 {
+		if ratio := float64(m.discarded) / float64(records); ratio > t.MaxDiscardRatio {
+			errs = append(errs, fmt.Errorf("%w: %d of %d records discarded (%.2f%%), above the limit of %.2f%%",
+				ErrImportThreshold, m.discarded, records, ratio*100, t.MaxDiscardRatio*100))
+		}
+	}
+	if t.MaxChangeRatio > 0 && m.current > 0 {
+		if ratio := float64(m.changed) / float64(m.current); ratio > t.MaxChangeRatio {
+			errs = append(errs, fmt.Errorf("%w: %d of %d geolocations changed (%.2f%%), above the limit of %.2f%%",
+				ErrImportThreshold, m.changed, m.current, ratio*100, t.MaxChangeRatio*100))
+		}
+	}
+	if t.MaxCountryDelta > 0 {
+		codes := make([]string, 0, len(m.countries))
+		for code := range m.countries {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			c := m.countries[code]
+			if delta := countryDelta(c); delta > t.MaxCountryDelta {
+				errs = append(errs, fmt.Errorf("%w: geolocations of country %q change from %d to %d (%.2f%%), above the limit of %.2f%%",
+					ErrImportThreshold, code, c.before, c.after, delta*100, t.MaxCountryDelta*100))
+			}
+		}
+	}
+	if i.opts.Mode == ImportSync && i.opts.MaxPruneRatio > 0 && m.current > 0 {
+		if ratio := float64(m.prune) / float64(m.current); ratio > i.opts.MaxPruneRatio {
+			errs = append(errs, fmt.Errorf("%w: %d of %d (%.2f%%) is above the limit of %.2f%%",
+				ErrPruneLimit, m.prune, m.current, ratio*100, i.opts.MaxPruneRatio*100))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// countryDelta is the change of the number of geolocations of a country, relative to the largest of the numbers.
+func countryDelta(c countryCount) float64 {
+	largest := max(c.before, c.after)
+	if largest == 0 {
+		return 0
+	}
+	return float64(abs(c.after-c.before)) / float64(largest)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
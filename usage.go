@@ -0,0 +1,204 @@
+package vio
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage of the API by a client on a given day.
+type Usage struct {
+	// Client is identified as key:<api key ID> or ip:<address>.
+	Client  string
+	Day     time.Time
+	Lookups int64
+}
+
+// NewUsageTracker creates a tracker for counting the API usage of each client.
+func NewUsageTracker(db DB, log *slog.Logger) *UsageTracker {
+	return &UsageTracker{
+		db:      db,
+		log:     log,
+		pending: map[usageKey]int64{},
+		monthly: map[string]monthlyUsage{},
+	}
+}
+
+// UsageTracker counts lookups per client and day in memory and flushes them periodically to the database.
+type UsageTracker struct {
+	db  DB
+	log *slog.Logger
+
+	// flushMu is held by Flush while saving the pending counters, so reads of the usage
+	// don't miss the counters being saved, nor count them twice.
+	flushMu sync.RWMutex
+
+	mu sync.Mutex // guards following
+
+	// pending usage counters not flushed to the database yet.
+	pending map[usageKey]int64
+
+	// monthly usage of clients, cached until the next flush.
+	monthly map[string]monthlyUsage
+}
+
+type usageKey struct {
+	client string
+	day    time.Time
+}
+
+type monthlyUsage struct {
+	month   time.Time
+	lookups int64
+}
+
+// day returns the UTC day of t.
+func day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// month returns the first day of the UTC month of t.
+func month(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Record a lookup by client.
+func (t *UsageTracker) Record(client string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[usageKey{client: client, day: day(now)}]++
+	if mu, ok := t.monthly[client]; ok && mu.month.Equal(month(now)) {
+		mu.lookups++
+		t.monthly[client] = mu
+	}
+}
+
+// MonthlyUsage returns the number of lookups of a client during the current month.
+func (t *UsageTracker) MonthlyUsage(ctx context.Context, client string, now time.Time) (int64, error) {
+	m := month(now)
+	t.mu.Lock()
+	if mu, ok := t.monthly[client]; ok && mu.month.Equal(m) {
+		t.mu.Unlock()
+		return mu.lookups, nil
+	}
+	t.mu.Unlock()
+
+	// The value is cached before a flush can clear the cache.
+	t.flushMu.RLock()
+	defer t.flushMu.RUnlock()
+	usage, err := t.db.GetUsage(ctx, client, m, day(now))
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Another request might have cached the value in the meantime, so don't overwrite it.
+	if mu, ok := t.monthly[client]; ok && mu.month.Equal(m) {
+		return mu.lookups, nil
+	}
+	var lookups int64
+	for _, u := range t.addPending(usage, client, now) {
+		lookups += u.Lookups
+	}
+	t.monthly[client] = monthlyUsage{month: m, lookups: lookups}
+	return lookups, nil
+}
+
+// Usage returns the daily usage of a client during the current month, including lookups not flushed yet.
+func (t *UsageTracker) Usage(ctx context.Context, client string, now time.Time) ([]Usage, error) {
+	t.flushMu.RLock()
+	defer t.flushMu.RUnlock()
+	usage, err := t.db.GetUsage(ctx, client, month(now), day(now))
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.addPending(usage, client, now), nil
+}
+
+// addPending adds the lookups of the client not flushed yet to its daily usage during the current month.
+// t.mu must be held.
+func (t *UsageTracker) addPending(usage []Usage, client string, now time.Time) []Usage {
+	from, to := month(now), day(now)
+	for k, lookups := range t.pending {
+		if k.client != client || k.day.Before(from) || k.day.After(to) {
+			continue
+		}
+		found := false
+		for i := range usage {
+			if usage[i].Day.Equal(k.day) {
+				usage[i].Lookups += lookups
+				found = true
+				break
+			}
+		}
+		if !found {
+			usage = append(usage, Usage{Client: client, Day: k.day, Lookups: lookups})
+		}
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Day.Before(usage[j].Day)
+	})
+	return usage
+}
+
+// Flush pending usage counters to the database.
+// Counters are kept in memory if the database is unavailable.
+func (t *UsageTracker) Flush(ctx context.Context) error {
+	t.flushMu.Lock()
+	defer t.flushMu.Unlock()
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = map[usageKey]int64{}
+	t.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	usage := make([]Usage, 0, len(pending))
+	for k, lookups := range pending {
+		usage = append(usage, Usage{Client: k.client, Day: k.day, Lookups: lookups})
+	}
+	err := t.db.AddUsage(ctx, usage)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		for k, lookups := range pending {
+			t.pending[k] += lookups
+		}
+		return err
+	}
+	// Reload monthly usage from the database to account for other instances of the service.
+	clear(t.monthly)
+	return nil
+}
+
+// Run flushes usage counters to the database periodically until the context is canceled.
+func (t *UsageTracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Flush(ctx); err != nil {
+				t.log.Error("cannot flush usage counters", slog.Any("error", err))
+			}
+		case <-ctx.Done():
+			// Try to save what is left before exiting.
+			haltCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			if err := t.Flush(haltCtx); err != nil {
+				t.log.Error("cannot flush usage counters", slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
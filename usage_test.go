@@ -0,0 +1,157 @@
+package vio_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/henvic/pgtools/sqltest"
+	"github.com/henvic/vio"
+	"github.com/henvic/vio/internal/mock"
+	"go.uber.org/mock/gomock"
+)
+
+func TestUsageTracker(t *testing.T) {
+	t.Parallel()
+	migration := sqltest.New(t, sqltest.Options{
+		Force: *force,
+		Files: os.DirFS("migrations"),
+	})
+	pool := migration.Setup(context.Background(), "")
+	ctx := context.Background()
+	tracker := vio.NewUsageTracker(vio.NewPostgres(pool, slog.Default()), slog.Default())
+
+	var (
+		lastMonth = time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC)
+		day1      = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+		day2      = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	)
+	tracker.Record("key:1", lastMonth)
+	tracker.Record("key:1", day1)
+	tracker.Record("key:1", day1)
+	tracker.Record("ip:127.0.0.1", day1)
+	if err := tracker.Flush(ctx); err != nil {
+		t.Fatalf("UsageTracker.Flush() error = %v", err)
+	}
+	tracker.Record("key:1", day2)
+
+	got, err := tracker.Usage(ctx, "key:1", day2)
+	if err != nil {
+		t.Fatalf("UsageTracker.Usage() error = %v", err)
+	}
+	want := []vio.Usage{
+		{Client: "key:1", Day: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Lookups: 2},
+		{Client: "key:1", Day: time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), Lookups: 1},
+	}
+	if !cmp.Equal(want, got) {
+		t.Errorf("UsageTracker.Usage() mismatch: %v", cmp.Diff(want, got))
+	}
+
+	lookups, err := tracker.MonthlyUsage(ctx, "key:1", day2)
+	if err != nil {
+		t.Fatalf("UsageTracker.MonthlyUsage() error = %v", err)
+	}
+	if lookups != 3 {
+		t.Errorf("UsageTracker.MonthlyUsage() = %d, want 3", lookups)
+	}
+	tracker.Record("key:1", day2)
+	if lookups, _ = tracker.MonthlyUsage(ctx, "key:1", day2); lookups != 4 {
+		t.Errorf("UsageTracker.MonthlyUsage() after new lookup = %d, want 4", lookups)
+	}
+
+	// Flushing again must add to the existing counters.
+	if err := tracker.Flush(ctx); err != nil {
+		t.Fatalf("UsageTracker.Flush() error = %v", err)
+	}
+	if lookups, _ = tracker.MonthlyUsage(ctx, "key:1", day2); lookups != 4 {
+		t.Errorf("UsageTracker.MonthlyUsage() after flush = %d, want 4", lookups)
+	}
+}
+
+func TestUsageTrackerFlushError(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	m := mock.NewMockDB(ctrl)
+	gomock.InOrder(
+		m.EXPECT().AddUsage(gomock.Any(), gomock.Len(1)).Return(errors.New("unexpected error")),
+		m.EXPECT().AddUsage(gomock.Any(), []vio.Usage{
+			{Client: "key:1", Day: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Lookups: 2},
+		}).Return(nil),
+	)
+
+	tracker := vio.NewUsageTracker(m, slog.Default())
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	tracker.Record("key:1", now)
+	if err := tracker.Flush(context.Background()); err == nil {
+		t.Error("UsageTracker.Flush() should fail")
+	}
+	// Counters must be kept until they're saved.
+	tracker.Record("key:1", now)
+	if err := tracker.Flush(context.Background()); err != nil {
+		t.Errorf("UsageTracker.Flush() error = %v", err)
+	}
+	// Nothing to flush.
+	if err := tracker.Flush(context.Background()); err != nil {
+		t.Errorf("UsageTracker.Flush() error = %v", err)
+	}
+}
+
+func TestUsageTrackerMonthlyUsageDuringFlush(t *testing.T) {
+	t.Parallel()
+	ctrl := gomock.NewController(t)
+	m := mock.NewMockDB(ctrl)
+	var (
+		now      = time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+		day      = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+		flushing = make(chan struct{})
+		release  = make(chan struct{})
+		saved    atomic.Bool
+	)
+	m.EXPECT().AddUsage(gomock.Any(), gomock.Len(1)).DoAndReturn(func(context.Context, []vio.Usage) error {
+		close(flushing)
+		<-release
+		saved.Store(true)
+		return nil
+	})
+	m.EXPECT().GetUsage(gomock.Any(), "key:1", day, day).DoAndReturn(func(context.Context, string, time.Time, time.Time) ([]vio.Usage, error) {
+		if !saved.Load() {
+			return nil, nil
+		}
+		return []vio.Usage{{Client: "key:1", Day: day, Lookups: 1}}, nil
+	}).AnyTimes()
+
+	tracker := vio.NewUsageTracker(m, slog.Default())
+	tracker.Record("key:1", now)
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- tracker.Flush(context.Background())
+	}()
+	<-flushing
+
+	// The counters being saved are neither missed nor counted twice, and the value isn't cached before they're saved.
+	type result struct {
+		lookups int64
+		err     error
+	}
+	got := make(chan result, 1)
+	go func() {
+		lookups, err := tracker.MonthlyUsage(context.Background(), "key:1", now)
+		got <- result{lookups, err}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-flushed; err != nil {
+		t.Fatalf("UsageTracker.Flush() error = %v", err)
+	}
+	if r := <-got; r.err != nil || r.lookups != 1 {
+		t.Errorf("UsageTracker.MonthlyUsage() during flush = %d, %v, want 1", r.lookups, r.err)
+	}
+	if lookups, err := tracker.MonthlyUsage(context.Background(), "key:1", now); err != nil || lookups != 1 {
+		t.Errorf("UsageTracker.MonthlyUsage() after flush = %d, %v, want 1", lookups, err)
+	}
+}